@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/urfave/cli/v2"
+)
+
+// The values report activity can map, see --metric.
+const (
+	metricCount  = "count"
+	metricVolume = "volume"
+	metricFees   = "fees"
+)
+
+// weekdays are the rows of the activity heatmap, starting on Monday.
+var weekdays = []time.Weekday{
+	time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday,
+	time.Saturday, time.Sunday,
+}
+
+// activityGrid sums up a metric of the forwards per weekday and hour.
+type activityGrid [7][24]float64
+
+// weekdayRow returns the index of a weekday in weekdays.
+func weekdayRow(d time.Weekday) int {
+	return (int(d) + 6) % 7
+}
+
+// reportActivity prints a heatmap of the forwards by weekday and hour of the
+// day.
+func reportActivity(ctx *cli.Context) error {
+	ctxb := ctx.Context
+	client, err := getClient(ctxb, ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to LND: %w", err)
+	}
+	defer client.Close()
+
+	unit, err := getAmountUnit(ctx)
+	if err != nil {
+		return err
+	}
+
+	metric := ctx.String("metric")
+	switch metric {
+	case metricCount, metricVolume, metricFees:
+	default:
+		return fmt.Errorf("invalid --metric %q, should be %s, %s or %s",
+			metric, metricCount, metricVolume, metricFees)
+	}
+
+	loc, err := time.LoadLocation(ctx.String("tz"))
+	if err != nil {
+		return fmt.Errorf("invalid --tz: %w", err)
+	}
+	now := client.Now()
+
+	start, err := parseTimeSpec(ctx.String("since"), now, loc)
+	if err != nil {
+		return fmt.Errorf("invalid --since: %w", err)
+	}
+
+	end := now
+	if ctx.IsSet("until") {
+		end, err = parseTimeSpec(ctx.String("until"), now, loc)
+		if err != nil {
+			return fmt.Errorf("invalid --until: %w", err)
+		}
+	}
+
+	channels := make(map[uint64]bool)
+	ids, err := resolveChanIDs(ctxb, client.Client, ctx.StringSlice("channel"))
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		channels[id] = true
+	}
+
+	events, err := fetchForwards(ctxb, client.Client, start, end)
+	if err != nil {
+		return err
+	}
+
+	var (
+		grid  activityGrid
+		count int
+	)
+	for _, e := range events {
+		if len(channels) > 0 && !channels[e.ChannelIn] &&
+			!channels[e.ChannelOut] {
+
+			continue
+		}
+
+		t := e.Timestamp.In(loc)
+		cell := &grid[weekdayRow(t.Weekday())][t.Hour()]
+		switch metric {
+		case metricCount:
+			*cell++
+		case metricVolume:
+			*cell += float64(e.AmountMsatOut)
+		case metricFees:
+			*cell += float64(e.FeeMsat)
+		}
+		count++
+	}
+
+	// Counts are printed as they are, amounts in the --unit.
+	format := func(v float64) string {
+		if metric == metricCount {
+			return fmt.Sprint(int64(v))
+		}
+
+		return unit.format(lnwire.MilliSatoshi(math.Round(v)))
+	}
+
+	fmt.Printf("Forward %s by weekday and hour in %s, %s to %s, %d "+
+		"forwards\n\n", metric, loc, start.In(loc).Format(time.DateOnly),
+		end.In(loc).Format(time.DateOnly), count)
+	printActivity(&grid, format)
+
+	return nil
+}
+
+// printActivity prints the grid as a heatmap with the total of each
+// weekday, followed by the totals per hour and a legend.
+func printActivity(grid *activityGrid, format func(float64) string) {
+	var (
+		largest float64
+		days    [7]float64
+		hours   [24]float64
+	)
+	for d := range grid {
+		for h, v := range grid[d] {
+			largest = max(largest, v)
+			days[d] += v
+			hours[h] += v
+		}
+	}
+
+	// shade marks a value by its share of the largest one.
+	shade := func(v, largest float64) string {
+		if v == 0 {
+			return "·"
+		}
+
+		level := int(math.Ceil(v / largest * float64(len(heatShades))))
+		return heatShades[min(max(level, 1), len(heatShades))-1]
+	}
+
+	var b strings.Builder
+	b.WriteString("    ")
+	for h := range 24 {
+		fmt.Fprintf(&b, " %02d", h)
+	}
+	b.WriteString(" | Total")
+	line := strings.Repeat("-", b.Len())
+	fmt.Println(b.String())
+	fmt.Println(line)
+
+	for d, day := range weekdays {
+		b.Reset()
+		b.WriteString(day.String()[:3] + " ")
+		for _, v := range grid[d] {
+			s := shade(v, largest)
+			b.WriteString(" " + s + s)
+		}
+		b.WriteString(" | " + format(days[d]))
+		fmt.Println(b.String())
+	}
+
+	// The hours of the whole week are shaded by the busiest hour.
+	var busiestHour float64
+	for _, v := range hours {
+		busiestHour = max(busiestHour, v)
+	}
+
+	b.Reset()
+	b.WriteString("All ")
+	for _, v := range hours {
+		s := shade(v, busiestHour)
+		b.WriteString(" " + s + s)
+	}
+	var total float64
+	for _, v := range days {
+		total += v
+	}
+	b.WriteString(" | " + format(total))
+	fmt.Println(line)
+	fmt.Println(b.String())
+
+	fmt.Printf("\n· none  %s up to 25%%  %s up to 50%%  %s up to 75%%  %s up "+
+		"to 100%% of the busiest hour, %s\n", heatShades[0],
+		heatShades[1], heatShades[2], heatShades[3], format(largest))
+}
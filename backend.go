@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/lnrpc/routerrpc"
+	"github.com/lightningnetwork/lnd/routing/route"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	// lndBackend talks to a live lnd daemon.
+	lndBackend = "lnd"
+
+	// fixtureBackendPrefix is followed by the directory of recorded
+	// fixtures to serve the calls from.
+	fixtureBackendPrefix = "fixture:"
+)
+
+// lndAPI is the part of lnd's API that lnb uses. It is implemented by the
+// live lnd client as well as by the fixture backend, so every command works
+// the same against recorded data.
+type lndAPI interface {
+	// GetInfo returns general information concerning the lightning node.
+	GetInfo(ctx context.Context) (*lndclient.Info, error)
+
+	// ListChannels retrieves all channels of the backing lnd node.
+	ListChannels(ctx context.Context, activeOnly, publicOnly bool,
+		opts ...lndclient.ListChannelsOption) ([]lndclient.ChannelInfo,
+		error)
+
+	// ForwardingHistory makes a paginated call to our forwarding history
+	// endpoint.
+	ForwardingHistory(ctx context.Context,
+		req lndclient.ForwardingHistoryRequest) (
+		*lndclient.ForwardingHistoryResponse, error)
+
+	// GetNodeInfo returns the information of a node from the channel
+	// graph.
+	GetNodeInfo(ctx context.Context, pubkey route.Vertex,
+		includeChannels bool) (*lndclient.NodeInfo, error)
+
+	// GetChanInfo returns the channel edge, including both routing
+	// policies, from the channel graph.
+	GetChanInfo(ctx context.Context, chanID uint64) (
+		*lndclient.ChannelEdge, error)
+
+	// ClosedChannels returns all closed channels of the backing lnd node.
+	ClosedChannels(ctx context.Context) ([]lndclient.ClosedChannel, error)
+
+	// ListTransactions returns the on-chain transactions of the wallet
+	// between the two block heights, an end height of -1 includes
+	// unconfirmed ones.
+	ListTransactions(ctx context.Context, startHeight, endHeight int32,
+		opts ...lndclient.ListTransactionsOption) (
+		[]lndclient.Transaction, error)
+
+	// ListPayments makes a paginated call to our payments endpoint.
+	ListPayments(ctx context.Context, req lndclient.ListPaymentsRequest) (
+		*lndclient.ListPaymentsResponse, error)
+
+	// PendingChannels returns the channels of the backing lnd node which
+	// are being opened or closed.
+	PendingChannels(ctx context.Context) (*lndclient.PendingChannels,
+		error)
+
+	// WalletBalance returns the on-chain balance of the wallet.
+	WalletBalance(ctx context.Context) (*lndclient.WalletBalance, error)
+
+	// SubscribeChannelEvents streams the channel events of lnd until ctx
+	// is cancelled. The event channel is closed when the stream ends.
+	SubscribeChannelEvents(ctx context.Context) (
+		<-chan *lndclient.ChannelEventUpdate, <-chan error, error)
+
+	// SubscribeHtlcEvents streams the HTLC events of the router until ctx
+	// is cancelled. The event channel is closed when the stream ends.
+	SubscribeHtlcEvents(ctx context.Context) (<-chan *routerrpc.HtlcEvent,
+		<-chan error, error)
+}
+
+// lndClient joins the lndclient services lnb uses into a single lndAPI.
+type lndClient struct {
+	lndclient.LightningClient
+
+	router lndclient.RouterClient
+}
+
+// SubscribeHtlcEvents streams the HTLC events of the router.
+func (c *lndClient) SubscribeHtlcEvents(ctx context.Context) (
+	<-chan *routerrpc.HtlcEvent, <-chan error, error) {
+
+	return c.router.SubscribeHtlcEvents(ctx)
+}
+
+// lndServices is the backend the commands work with.
+type lndServices struct {
+	// Client serves the calls lnb makes.
+	Client lndAPI
+
+	// now returns the time the data of the backend is from, nil for the
+	// wall clock.
+	now func() time.Time
+
+	close func()
+}
+
+// Now returns the current time of the backend. A recording stays at the
+// time it was made, so reports of it don't change from day to day.
+func (s *lndServices) Now() time.Time {
+	if s.now != nil {
+		return s.now()
+	}
+
+	return time.Now()
+}
+
+// Close releases the connection to the backend.
+func (s *lndServices) Close() {
+	if s.close != nil {
+		s.close()
+	}
+}
+
+// wrapClient puts the rpc client around api. Every call lnb makes goes
+// through it, so a short hiccup of lnd doesn't abort a long running report
+// and concurrent lookups don't flood lnd.
+func wrapClient(ctx *cli.Context, api lndAPI) *rpcClient {
+	return newRPCClient(
+		api, retryPolicy{retries: ctx.Int("retries")},
+		ctx.Int("parallel"), rpcStats,
+	)
+}
+
+// fixtureBackend returns true if --backend serves a recording.
+func fixtureBackend(ctx *cli.Context) bool {
+	return strings.HasPrefix(ctx.String("backend"), fixtureBackendPrefix)
+}
+
+// getClient returns the backend selected with --backend.
+func getClient(callerCtx context.Context, ctx *cli.Context) (*lndServices,
+	error) {
+
+	backend := ctx.String("backend")
+	switch {
+	case backend == lndBackend:
+		return connectLnd(callerCtx, ctx)
+
+	case strings.HasPrefix(backend, fixtureBackendPrefix):
+		dir := cleanAndExpandPath(
+			strings.TrimPrefix(backend, fixtureBackendPrefix),
+		)
+		fixtures, err := loadFixtures(dir)
+		if err != nil {
+			return nil, err
+		}
+
+		return &lndServices{
+			Client: wrapClient(ctx, fixtures),
+			now:    fixtures.now,
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("invalid --backend %q, should be %s or "+
+			"%s<dir>", backend, lndBackend, fixtureBackendPrefix)
+	}
+}
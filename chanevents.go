@@ -0,0 +1,458 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/routing/route"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	// The rules watch channels alerts on.
+	ruleInactive   = "inactive"
+	ruleForceClose = "force_close"
+	ruleLowRatio   = "low_ratio"
+
+	// defaultCheckInterval is how often watch channels polls the balances
+	// and checks for how long channels are inactive.
+	defaultCheckInterval = time.Minute
+
+	// channelEventFormat is the line watch channels prints per event and
+	// alert.
+	channelEventFormat = "%-19s  %-14s  %-20s  %-10s  %s\n"
+)
+
+// channelUpdateNames are the names watch channels prints the channel events
+// by.
+var channelUpdateNames = map[lndclient.ChannelUpdateType]string{
+	lndclient.PendingOpenChannelUpdate:   "pending_open",
+	lndclient.OpenChannelUpdate:          "open",
+	lndclient.ClosedChannelUpdate:        "closed",
+	lndclient.ActiveChannelUpdate:        "active",
+	lndclient.InactiveChannelUpdate:      "inactive",
+	lndclient.FullyResolvedChannelUpdate: "fully_resolved",
+}
+
+// closeTypeNames describe how a channel was closed.
+var closeTypeNames = map[lndclient.CloseType]string{
+	lndclient.CloseTypeCooperative:      "cooperative close",
+	lndclient.CloseTypeLocalForce:       "local force close",
+	lndclient.CloseTypeRemoteForce:      "remote force close",
+	lndclient.CloseTypeBreach:           "breach",
+	lndclient.CloseTypeFundingCancelled: "funding cancelled",
+	lndclient.CloseTypeAbandoned:        "abandoned",
+}
+
+// channelAlert is what the alert hooks receive when a rule fires.
+type channelAlert struct {
+	Time         time.Time `json:"time"`
+	Rule         string    `json:"rule"`
+	ChannelID    uint64    `json:"chan_id"`
+	ChannelPoint string    `json:"channel_point"`
+	Peer         string    `json:"peer"`
+	Alias        string    `json:"alias,omitempty"`
+	Message      string    `json:"message"`
+}
+
+// alertRules are the thresholds watch channels alerts on. Zero values
+// disable a rule.
+type alertRules struct {
+	inactiveFor time.Duration
+	forceClose  bool
+	minRatio    float64
+}
+
+// any returns whether at least one rule is enabled.
+func (r *alertRules) any() bool {
+	return r.inactiveFor > 0 || r.forceClose || r.minRatio > 0
+}
+
+// watchedChannel is the last known state of an open channel.
+type watchedChannel struct {
+	lndclient.ChannelInfo
+
+	// inactiveSince is when the channel was first seen inactive, zero
+	// while it is active.
+	inactiveSince time.Time
+}
+
+// ratio returns the share of the local balance in percent.
+func (c *watchedChannel) ratio() float64 {
+	if c.LocalBalance+c.RemoteBalance == 0 {
+		return 0
+	}
+
+	return float64(c.LocalBalance) /
+		float64(c.LocalBalance+c.RemoteBalance) * 100
+}
+
+// alertKey identifies a rule which fired for a channel.
+type alertKey struct {
+	chanPoint string
+	rule      string
+}
+
+// channelWatcher follows the open channels and alerts when they break a
+// rule. An alert fires once, and again only after the channel recovered.
+type channelWatcher struct {
+	rules   alertRules
+	hooks   *alertHooks
+	f       *rowFormat
+	loc     *time.Location
+	aliases map[route.Vertex]string
+
+	// channels are the open channels by channel point, closed the ones
+	// which closed while watching, until they are fully resolved.
+	channels map[string]*watchedChannel
+	closed   map[string]*lndclient.ClosedChannel
+	alerted  map[alertKey]bool
+}
+
+// peer names the peer of a channel by its alias if it is known.
+func (w *channelWatcher) peer(node route.Vertex) string {
+	if alias := w.aliases[node]; alias != "" {
+		return truncate(alias, 10)
+	}
+
+	return hex.EncodeToString(node[:4])
+}
+
+// channel names a channel by its id, - if it has none yet.
+func (w *channelWatcher) channel(chanID uint64) string {
+	if chanID == 0 {
+		return "-"
+	}
+
+	return strings.TrimSpace(w.f.chanFmt.format(chanID))
+}
+
+// sync updates the channels from a listing of all open ones.
+func (w *channelWatcher) sync(channels []lndclient.ChannelInfo,
+	now time.Time) {
+
+	open := make(map[string]*watchedChannel, len(channels))
+	for _, c := range channels {
+		watched, ok := w.channels[c.ChannelPoint]
+		if !ok {
+			watched = &watchedChannel{}
+		}
+		watched.ChannelInfo = c
+		w.setActive(watched, c.Active, now)
+
+		open[c.ChannelPoint] = watched
+	}
+
+	w.channels = open
+}
+
+// setActive records whether a channel is active. An active channel is no
+// longer alerted on as inactive.
+func (w *channelWatcher) setActive(c *watchedChannel, active bool,
+	now time.Time) {
+
+	c.Active = active
+	switch {
+	case active:
+		c.inactiveSince = time.Time{}
+		delete(w.alerted, alertKey{c.ChannelPoint, ruleInactive})
+
+	case c.inactiveSince.IsZero():
+		c.inactiveSince = now
+	}
+}
+
+// handle prints a channel event and applies it to the channels.
+func (w *channelWatcher) handle(ctx context.Context,
+	u *lndclient.ChannelEventUpdate, now time.Time) {
+
+	var chanPoint string
+	if u.ChannelPoint != nil {
+		chanPoint = u.ChannelPoint.String()
+	}
+
+	var (
+		chanID uint64
+		peer   = "-"
+		detail string
+	)
+	if c, ok := w.channels[chanPoint]; ok {
+		chanID, peer = c.ChannelID, w.peer(c.PubKeyBytes)
+	}
+	if c, ok := w.closed[chanPoint]; ok {
+		chanID, peer = c.ChannelID, w.peer(c.PubKeyBytes)
+	}
+
+	switch u.UpdateType {
+	case lndclient.PendingOpenChannelUpdate:
+		detail = chanPoint
+
+	case lndclient.OpenChannelUpdate:
+		if u.OpenedChannelInfo == nil {
+			break
+		}
+
+		c := &watchedChannel{}
+		c.ChannelInfo = *u.OpenedChannelInfo
+		w.setActive(c, c.Active, now)
+		w.channels[c.ChannelPoint] = c
+
+		chanID, peer = c.ChannelID, w.peer(c.PubKeyBytes)
+		detail = fmt.Sprintf("capacity %s, local %s",
+			w.f.unit.format(sat(c.Capacity)),
+			w.f.unit.format(sat(c.LocalBalance)))
+
+	case lndclient.ClosedChannelUpdate:
+		if u.ClosedChannelInfo == nil {
+			break
+		}
+
+		closed := u.ClosedChannelInfo
+		chanID, peer = closed.ChannelID, w.peer(closed.PubKeyBytes)
+		detail = fmt.Sprintf("%s, settled %s",
+			closeTypeNames[closed.CloseType],
+			w.f.unit.format(sat(closed.SettledBalance)))
+
+	case lndclient.FullyResolvedChannelUpdate:
+		delete(w.closed, chanPoint)
+
+	case lndclient.ActiveChannelUpdate, lndclient.InactiveChannelUpdate:
+		if c, ok := w.channels[chanPoint]; ok {
+			w.setActive(
+				c, u.UpdateType == lndclient.ActiveChannelUpdate,
+				now,
+			)
+		}
+	}
+
+	fmt.Printf(channelEventFormat, now.In(w.loc).Format(time.DateTime),
+		channelUpdateNames[u.UpdateType], w.channel(chanID), peer, detail)
+
+	if u.UpdateType != lndclient.ClosedChannelUpdate ||
+		u.ClosedChannelInfo == nil {
+
+		return
+	}
+
+	closed := u.ClosedChannelInfo
+	delete(w.channels, closed.ChannelPoint)
+	w.closed[closed.ChannelPoint] = closed
+	for _, rule := range []string{ruleInactive, ruleLowRatio} {
+		delete(w.alerted, alertKey{closed.ChannelPoint, rule})
+	}
+
+	switch closed.CloseType {
+	case lndclient.CloseTypeLocalForce, lndclient.CloseTypeRemoteForce,
+		lndclient.CloseTypeBreach:
+
+		if !w.rules.forceClose {
+			return
+		}
+
+		w.alert(ctx, ruleForceClose, closed.ChannelID,
+			closed.ChannelPoint, closed.PubKeyBytes,
+			closeTypeNames[closed.CloseType], now)
+	}
+}
+
+// check alerts on the channels which are inactive for too long or whose
+// local ratio is too low.
+func (w *channelWatcher) check(ctx context.Context, now time.Time) {
+	for _, c := range w.channels {
+		inactive := now.Sub(c.inactiveSince)
+		if w.rules.inactiveFor > 0 && !c.inactiveSince.IsZero() &&
+			inactive >= w.rules.inactiveFor {
+
+			w.alert(ctx, ruleInactive, c.ChannelID, c.ChannelPoint,
+				c.PubKeyBytes, fmt.Sprintf("inactive for %d minutes",
+					int64(inactive.Minutes())), now)
+		}
+
+		if w.rules.minRatio <= 0 {
+			continue
+		}
+
+		if c.ratio() >= w.rules.minRatio {
+			delete(w.alerted, alertKey{c.ChannelPoint, ruleLowRatio})
+			continue
+		}
+		w.alert(ctx, ruleLowRatio, c.ChannelID, c.ChannelPoint,
+			c.PubKeyBytes, fmt.Sprintf("local ratio %d%% below %g%%",
+				int64(math.Round(c.ratio())), w.rules.minRatio),
+			now)
+	}
+}
+
+// alert prints an alert of a rule about a channel and runs the hooks, unless
+// the rule already fired for the channel. A failing hook doesn't stop the
+// watch.
+func (w *channelWatcher) alert(ctx context.Context, rule string,
+	chanID uint64, chanPoint string, node route.Vertex, detail string,
+	now time.Time) {
+
+	key := alertKey{chanPoint, rule}
+	if w.alerted[key] {
+		return
+	}
+	w.alerted[key] = true
+
+	fmt.Printf(channelEventFormat, now.In(w.loc).Format(time.DateTime),
+		"alert", w.channel(chanID), w.peer(node), rule+": "+detail)
+
+	alert := &channelAlert{
+		Time:         now,
+		Rule:         rule,
+		ChannelID:    chanID,
+		ChannelPoint: chanPoint,
+		Peer:         hex.EncodeToString(node[:]),
+		Alias:        w.aliases[node],
+		Message: fmt.Sprintf("channel %s with %s: %s",
+			w.channel(chanID), w.peer(node), detail),
+	}
+	if err := w.hooks.run(ctx, alert); err != nil {
+		fmt.Fprintf(os.Stderr, "[lnb] alert hook failed: %v\n", err)
+	}
+}
+
+// watchChannels prints the channel events as they happen and alerts on the
+// configured rules until interrupted.
+func watchChannels(ctx *cli.Context) error {
+	ctxb := ctx.Context
+	client, err := getClient(ctxb, ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to LND: %w", err)
+	}
+	defer client.Close()
+
+	chanFmt, err := getChanFormat(ctx)
+	if err != nil {
+		return err
+	}
+
+	unit, err := getAmountUnit(ctx)
+	if err != nil {
+		return err
+	}
+
+	loc, err := time.LoadLocation(ctx.String("tz"))
+	if err != nil {
+		return fmt.Errorf("invalid --tz: %w", err)
+	}
+
+	rules := alertRules{
+		inactiveFor: time.Duration(ctx.Int("inactive-minutes")) *
+			time.Minute,
+		forceClose: ctx.Bool("force-close"),
+		minRatio:   ctx.Float64("min-ratio"),
+	}
+	switch {
+	case rules.inactiveFor < 0:
+		return fmt.Errorf("--inactive-minutes can't be negative")
+
+	case rules.minRatio < 0 || rules.minRatio > 100:
+		return fmt.Errorf("--min-ratio should be between 0 and 100")
+	}
+
+	interval := ctx.Duration("interval")
+	if interval <= 0 {
+		return fmt.Errorf("--interval should be positive")
+	}
+
+	httpClient, err := newHTTPClient(
+		ctx.String("proxy"), ctx.Duration("http-timeout"),
+	)
+	if err != nil {
+		return err
+	}
+
+	hooks := &alertHooks{
+		webhook: ctx.String("webhook"),
+		script:  ctx.String("exec"),
+		client:  httpClient,
+	}
+	if hooks.configured() && !rules.any() {
+		return fmt.Errorf("--webhook and --exec need at least one of " +
+			"--inactive-minutes, --force-close or --min-ratio")
+	}
+
+	// Subscribe first so no event is missed between the listing and
+	// the subscription.
+	updates, errs, err := client.Client.SubscribeChannelEvents(ctxb)
+	if err != nil {
+		return fmt.Errorf("unable to subscribe to channel events: %w",
+			err)
+	}
+
+	channels, err := client.Client.ListChannels(ctxb, false, false)
+	if err != nil {
+		return err
+	}
+
+	w := &channelWatcher{
+		rules:    rules,
+		hooks:    hooks,
+		f:        &rowFormat{chanFmt: chanFmt, unit: unit},
+		loc:      loc,
+		channels: make(map[string]*watchedChannel),
+		closed:   make(map[string]*lndclient.ClosedChannel),
+		alerted:  make(map[alertKey]bool),
+	}
+	if ctx.Bool("alias") {
+		peers := make([]route.Vertex, 0, len(channels))
+		for _, c := range channels {
+			peers = append(peers, c.PubKeyBytes)
+		}
+
+		w.aliases, err = lookupAliases(ctxb, client.Client, peers)
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf(channelEventFormat, "Time", "Event", "Channel ID", "Peer",
+		"Detail")
+
+	w.sync(channels, time.Now())
+	w.check(ctxb, time.Now())
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case u, ok := <-updates:
+			if !ok {
+				return nil
+			}
+
+			w.handle(ctxb, u, time.Now())
+
+		case <-ticker.C:
+			channels, err := client.Client.ListChannels(
+				ctxb, false, false,
+			)
+			if err != nil {
+				return err
+			}
+
+			w.sync(channels, time.Now())
+			w.check(ctxb, time.Now())
+
+		case err := <-errs:
+			if ctxb.Err() != nil {
+				return nil
+			}
+
+			return fmt.Errorf("channel event stream failed: %w", err)
+
+		case <-ctxb.Done():
+			return nil
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/urfave/cli/v2"
+)
+
+// The ways a channel id can be printed, see --chan-format.
+const (
+	// chanFormatColon is lnb's own block:tx:output format, padded so the
+	// ids line up in tables.
+	chanFormatColon = "colon"
+
+	// chanFormatSCID is the blockxtxxoutput format of the BOLTs which
+	// most other tools use, padded like the colon format.
+	chanFormatSCID = "scid"
+
+	// chanFormatInt is the numeric channel id lnd uses.
+	chanFormatInt = "int"
+)
+
+// chanFormat prints channel ids in one of the formats above.
+type chanFormat string
+
+// getChanFormat returns the format selected with --chan-format.
+func getChanFormat(ctx *cli.Context) (chanFormat, error) {
+	switch f := ctx.String("chan-format"); f {
+	case chanFormatColon, chanFormatSCID, chanFormatInt:
+		return chanFormat(f), nil
+
+	default:
+		return "", fmt.Errorf("invalid --chan-format %q, should be %s, "+
+			"%s or %s", f, chanFormatColon, chanFormatSCID,
+			chanFormatInt)
+	}
+}
+
+// format returns the channel id as text.
+func (f chanFormat) format(chanID uint64) string {
+	c := lnwire.NewShortChanIDFromInt(chanID)
+
+	switch f {
+	case chanFormatSCID:
+		scid := fmt.Sprintf("%dx%dx%d", c.BlockHeight, c.TxIndex,
+			c.TxPosition)
+		return fmt.Sprintf("%14s", scid)
+
+	case chanFormatInt:
+		return strconv.FormatUint(chanID, 10)
+
+	default:
+		return fmt.Sprintf("%7d:%04d:%1d", c.BlockHeight, c.TxIndex,
+			c.TxPosition)
+	}
+}
+
+// parseChanID parses a channel given as
+//   - block:tx:output, e.g. 650000:1234:0, as lnb prints it,
+//   - blockxtxxoutput, e.g. 650000x1234x0, as most other tools print it,
+//   - the numeric channel id lnd uses, e.g. 714691092584103936,
+//   - the txid:output of its funding transaction, i.e. the channel point.
+//
+// The id of a channel point can't be known without asking lnd, so for those
+// a zero id and the normalized channel point are returned.
+func parseChanID(s string) (uint64, string, error) {
+	s = strings.TrimSpace(s)
+
+	if id, err := strconv.ParseUint(s, 10, 64); err == nil {
+		return id, "", nil
+	}
+
+	for _, sep := range []string{":", "x"} {
+		p := strings.Split(s, sep)
+		if len(p) != 3 {
+			continue
+		}
+
+		block, err1 := strconv.ParseUint(p[0], 10, 24)
+		tx, err2 := strconv.ParseUint(p[1], 10, 24)
+		output, err3 := strconv.ParseUint(p[2], 10, 16)
+		if err1 != nil || err2 != nil || err3 != nil {
+			break
+		}
+
+		c := lnwire.ShortChannelID{
+			BlockHeight: uint32(block),
+			TxIndex:     uint32(tx),
+			TxPosition:  uint16(output),
+		}
+
+		return c.ToUint64(), "", nil
+	}
+
+	txid, output, ok := strings.Cut(s, ":")
+	if ok {
+		_, err := hex.DecodeString(txid)
+		index, err2 := strconv.ParseUint(output, 10, 32)
+		if len(txid) == 64 && err == nil && err2 == nil {
+			chanPoint := fmt.Sprintf("%s:%d", strings.ToLower(txid),
+				index)
+
+			return 0, chanPoint, nil
+		}
+	}
+
+	return 0, "", fmt.Errorf("invalid channel %q, should be "+
+		"block:tx:output, blockxtxxoutput, a numeric channel id or "+
+		"txid:output", s)
+}
+
+// resolveChanIDs parses the given channels and returns their ids. Channel
+// points are looked up among our open channels first and, as the history
+// outlives the channels, among the closed ones after that.
+func resolveChanIDs(ctx context.Context, client lndAPI,
+	channels []string) ([]uint64, error) {
+
+	ids := make([]uint64, 0, len(channels))
+
+	var chanPoints map[string]uint64
+	closedLoaded := false
+	for _, s := range channels {
+		id, chanPoint, err := parseChanID(s)
+		if err != nil {
+			return nil, err
+		}
+
+		if chanPoint == "" {
+			ids = append(ids, id)
+			continue
+		}
+
+		if chanPoints == nil {
+			open, err := client.ListChannels(ctx, false, false)
+			if err != nil {
+				return nil, err
+			}
+
+			chanPoints = make(map[string]uint64, len(open))
+			for _, c := range open {
+				chanPoints[c.ChannelPoint] = c.ChannelID
+			}
+		}
+
+		id, ok := chanPoints[chanPoint]
+		if !ok && !closedLoaded {
+			closed, err := client.ClosedChannels(ctx)
+			if err != nil {
+				return nil, err
+			}
+			closedLoaded = true
+
+			for _, c := range closed {
+				// A channel which never confirmed has no id.
+				if c.ChannelID == 0 {
+					continue
+				}
+				chanPoints[c.ChannelPoint] = c.ChannelID
+			}
+
+			id, ok = chanPoints[chanPoint]
+		}
+		if !ok {
+			return nil, fmt.Errorf("no channel with channel point %s",
+				chanPoint)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
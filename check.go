@@ -0,0 +1,335 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/lightninglabs/lndclient"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/sync/errgroup"
+)
+
+// The states of a monitoring plugin, which are the exit codes of lnb check.
+const (
+	checkOK = iota
+	checkWarning
+	checkCritical
+	checkUnknown
+)
+
+// checkStateNames are the names of the states in the summary line.
+var checkStateNames = []string{"OK", "WARNING", "CRITICAL", "UNKNOWN"}
+
+// threshold is a warning and a critical limit of a check, either of which
+// may be unset.
+type threshold struct {
+	warn, crit       float64
+	hasWarn, hasCrit bool
+}
+
+// parseThreshold parses a threshold given as warn,crit. Either limit may be
+// left out, as in 5 or ,10, an empty string disables the check.
+func parseThreshold(s string) (threshold, error) {
+	var t threshold
+	warn, crit, _ := strings.Cut(s, ",")
+
+	if warn = strings.TrimSpace(warn); warn != "" {
+		v, err := strconv.ParseFloat(warn, 64)
+		if err != nil {
+			return t, fmt.Errorf("invalid warning limit %q", warn)
+		}
+		t.warn, t.hasWarn = v, true
+	}
+
+	if crit = strings.TrimSpace(crit); crit != "" {
+		v, err := strconv.ParseFloat(crit, 64)
+		if err != nil {
+			return t, fmt.Errorf("invalid critical limit %q", crit)
+		}
+		t.crit, t.hasCrit = v, true
+	}
+
+	return t, nil
+}
+
+// enabled returns whether any limit is set.
+func (t threshold) enabled() bool {
+	return t.hasWarn || t.hasCrit
+}
+
+// above returns the state of a value which should stay at or below the
+// limits.
+func (t threshold) above(v float64) int {
+	switch {
+	case t.hasCrit && v > t.crit:
+		return checkCritical
+
+	case t.hasWarn && v > t.warn:
+		return checkWarning
+
+	default:
+		return checkOK
+	}
+}
+
+// below returns the state of a value which should stay at or above the
+// limits.
+func (t threshold) below(v float64) int {
+	switch {
+	case t.hasCrit && v < t.crit:
+		return checkCritical
+
+	case t.hasWarn && v < t.warn:
+		return checkWarning
+
+	default:
+		return checkOK
+	}
+}
+
+// perfData returns a performance data entry of the value with the limits.
+func (t threshold) perfData(label string, v float64) string {
+	limit := func(v float64, ok bool) string {
+		if !ok {
+			return ""
+		}
+
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+
+	return fmt.Sprintf("%s=%s;%s;%s;0", label,
+		strconv.FormatFloat(v, 'f', -1, 64), limit(t.warn, t.hasWarn),
+		limit(t.crit, t.hasCrit))
+}
+
+// parseVersion returns the major, minor and patch number of an lnd version
+// like 0.19.3-beta commit=v0.19.3-beta.
+func parseVersion(version string) ([3]int, error) {
+	var parsed [3]int
+
+	fields := strings.Fields(version)
+	if len(fields) == 0 {
+		return parsed, fmt.Errorf("empty version")
+	}
+	number, _, _ := strings.Cut(strings.TrimPrefix(fields[0], "v"), "-")
+
+	parts := strings.Split(number, ".")
+	if len(parts) > len(parsed) {
+		return parsed, fmt.Errorf("invalid version %q", version)
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return parsed, fmt.Errorf("invalid version %q", version)
+		}
+		parsed[i] = n
+	}
+
+	return parsed, nil
+}
+
+// checkResult is the outcome of one check, with the text the summary shows
+// if it isn't OK.
+type checkResult struct {
+	state int
+	text  string
+}
+
+// checkNode checks the health of the node against the thresholds and
+// prints a one-line summary with performance data, the way monitoring
+// plugins do. It exits with the state of the worst check.
+func checkNode(ctx *cli.Context) error {
+	state, summary := runChecks(ctx)
+	fmt.Printf("LND %s - %s\n", checkStateNames[state], summary)
+
+	if state == checkOK {
+		return nil
+	}
+
+	return cli.Exit("", state)
+}
+
+// runChecks runs all checks and returns the worst state with the summary
+// line. Failing to get the data is an unknown state.
+func runChecks(ctx *cli.Context) (int, string) {
+	ctxb := ctx.Context
+
+	thresholds := make(map[string]threshold)
+	for _, name := range []string{
+		"inactive", "pending-force-closes", "wallet-balance",
+		"block-age",
+	} {
+		t, err := parseThreshold(ctx.String(name))
+		if err != nil {
+			return checkUnknown, fmt.Sprintf("invalid --%s: %v",
+				name, err)
+		}
+		thresholds[name] = t
+	}
+
+	var minVersion [3]int
+	if ctx.String("min-version") != "" {
+		var err error
+		minVersion, err = parseVersion(ctx.String("min-version"))
+		if err != nil {
+			return checkUnknown, fmt.Sprintf("invalid "+
+				"--min-version: %v", err)
+		}
+	}
+
+	client, err := getClient(ctxb, ctx)
+	if err != nil {
+		return checkUnknown, fmt.Sprintf("failed to connect to LND: %v",
+			err)
+	}
+	defer client.Close()
+
+	var (
+		info    *lndclient.Info
+		pending *lndclient.PendingChannels
+		wallet  *lndclient.WalletBalance
+	)
+	g, gctx := errgroup.WithContext(ctxb)
+	g.Go(func() error {
+		var err error
+		info, err = client.Client.GetInfo(gctx)
+		return err
+	})
+	g.Go(func() error {
+		var err error
+		pending, err = client.Client.PendingChannels(gctx)
+		return err
+	})
+	if thresholds["wallet-balance"].enabled() {
+		g.Go(func() error {
+			var err error
+			wallet, err = client.Client.WalletBalance(gctx)
+			return err
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return checkUnknown, err.Error()
+	}
+
+	var (
+		results []checkResult
+		perf    []string
+	)
+	check := func(state int, format string, args ...interface{}) {
+		results = append(results, checkResult{
+			state: state,
+			text:  fmt.Sprintf(format, args...),
+		})
+	}
+	flag := func(b bool) int {
+		if b {
+			return 1
+		}
+
+		return 0
+	}
+
+	// Without the chain lnd can't act on anything, a stale graph only
+	// makes its payments worse.
+	synced := checkOK
+	if !info.SyncedToChain {
+		synced = checkCritical
+	}
+	check(synced, "not synced to chain")
+
+	synced = checkOK
+	if !info.SyncedToGraph {
+		synced = checkWarning
+	}
+	check(synced, "not synced to graph")
+	perf = append(perf,
+		fmt.Sprintf("synced_to_chain=%d", flag(info.SyncedToChain)),
+		fmt.Sprintf("synced_to_graph=%d", flag(info.SyncedToGraph)),
+		fmt.Sprintf("active_channels=%d", info.ActiveChannels),
+	)
+
+	t := thresholds["inactive"]
+	check(t.above(float64(info.InactiveChannels)), "%d inactive channels",
+		info.InactiveChannels)
+	perf = append(perf, t.perfData(
+		"inactive_channels", float64(info.InactiveChannels),
+	))
+
+	t = thresholds["pending-force-closes"]
+	forceCloses := len(pending.PendingForceClose)
+	check(t.above(float64(forceCloses)), "%d pending force closes",
+		forceCloses)
+	perf = append(perf, t.perfData(
+		"pending_force_closes", float64(forceCloses),
+	))
+
+	if t = thresholds["wallet-balance"]; t.enabled() {
+		check(t.below(float64(wallet.Confirmed)),
+			"wallet balance %d sat", wallet.Confirmed)
+		perf = append(perf, t.perfData(
+			"wallet_balance", float64(wallet.Confirmed),
+		))
+	}
+
+	// The chain tip isn't known without another source, the age of the
+	// best block tells if lnd fell behind it though.
+	blockAge := client.Now().Sub(info.BestHeaderTimeStamp)
+	t = thresholds["block-age"]
+	check(t.above(blockAge.Minutes()), "last block %d minutes ago",
+		int64(blockAge.Minutes()))
+	perf = append(perf,
+		fmt.Sprintf("block_height=%d", info.BlockHeight),
+		fmt.Sprintf("block_age=%ds;%s;%s;0", int64(blockAge.Seconds()),
+			minutesToSeconds(t.warn, t.hasWarn),
+			minutesToSeconds(t.crit, t.hasCrit)),
+	)
+
+	if ctx.String("min-version") != "" {
+		version, err := parseVersion(info.Version)
+		if err != nil {
+			return checkUnknown, err.Error()
+		}
+
+		state := checkOK
+		for i := range version {
+			if version[i] != minVersion[i] {
+				if version[i] < minVersion[i] {
+					state = checkWarning
+				}
+				break
+			}
+		}
+		check(state, "lnd %s below %s", strings.Fields(info.Version)[0],
+			ctx.String("min-version"))
+	}
+
+	worst := checkOK
+	var problems []string
+	for _, r := range results {
+		if r.state == checkOK {
+			continue
+		}
+
+		worst = max(worst, r.state)
+		problems = append(problems, r.text)
+	}
+
+	summary := fmt.Sprintf("%d active channels, block %d",
+		info.ActiveChannels, info.BlockHeight)
+	if len(problems) > 0 {
+		summary = strings.Join(problems, ", ")
+	}
+
+	return worst, summary + " | " + strings.Join(perf, " ")
+}
+
+// minutesToSeconds formats a limit in minutes as seconds for performance
+// data, empty if it isn't set.
+func minutesToSeconds(minutes float64, ok bool) string {
+	if !ok {
+		return ""
+	}
+
+	return strconv.FormatFloat(minutes*60, 'f', -1, 64)
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"testing"
+)
+
+// TestParseThreshold checks the warn,crit limits with either left out.
+func TestParseThreshold(t *testing.T) {
+	tests := []struct {
+		s    string
+		want threshold
+		err  bool
+	}{
+		{s: "", want: threshold{}},
+		{s: "5", want: threshold{warn: 5, hasWarn: true}},
+		{s: ",10", want: threshold{crit: 10, hasCrit: true}},
+		{s: "5,", want: threshold{warn: 5, hasWarn: true}},
+		{
+			s: "2,5",
+			want: threshold{
+				warn: 2, crit: 5, hasWarn: true, hasCrit: true,
+			},
+		},
+		{
+			s: " 0.5 , 1e6 ",
+			want: threshold{
+				warn: 0.5, crit: 1e6, hasWarn: true,
+				hasCrit: true,
+			},
+		},
+		{s: "abc", err: true},
+		{s: "5,abc", err: true},
+		{s: "abc,5", err: true},
+		{s: "1,2,3", err: true},
+	}
+
+	for _, test := range tests {
+		got, err := parseThreshold(test.s)
+		switch {
+		case test.err && err == nil:
+			t.Errorf("%q was accepted as %+v", test.s, got)
+
+		case !test.err && err != nil:
+			t.Errorf("%q: %v", test.s, err)
+
+		case !test.err && got != test.want:
+			t.Errorf("%q parsed as %+v, want %+v", test.s, got,
+				test.want)
+		}
+	}
+
+	if t0, _ := parseThreshold(""); t0.enabled() {
+		t.Error("an empty threshold is enabled")
+	}
+	if t1, _ := parseThreshold(",10"); !t1.enabled() {
+		t.Error("a threshold with only a critical limit is disabled")
+	}
+}
+
+// TestThresholdStates checks the states of values against the limits, in
+// both directions.
+func TestThresholdStates(t *testing.T) {
+	both := threshold{warn: 2, crit: 5, hasWarn: true, hasCrit: true}
+	low := threshold{warn: 100, crit: 10, hasWarn: true, hasCrit: true}
+	critOnly := threshold{crit: 5, hasCrit: true}
+
+	tests := []struct {
+		name  string
+		state func(float64) int
+		v     float64
+		want  int
+	}{
+		{"above ok", both.above, 1, checkOK},
+		{"above at warn", both.above, 2, checkOK},
+		{"above warn", both.above, 3, checkWarning},
+		{"above at crit", both.above, 5, checkWarning},
+		{"above crit", both.above, 6, checkCritical},
+		{"above crit only", critOnly.above, 4, checkOK},
+		{"above crit only crit", critOnly.above, 6, checkCritical},
+		{"above off", threshold{}.above, 1e9, checkOK},
+		{"below ok", low.below, 200, checkOK},
+		{"below at warn", low.below, 100, checkOK},
+		{"below warn", low.below, 50, checkWarning},
+		{"below at crit", low.below, 10, checkWarning},
+		{"below crit", low.below, 0, checkCritical},
+		{"below crit only", critOnly.below, 6, checkOK},
+		{"below crit only crit", critOnly.below, 4, checkCritical},
+		{"below off", threshold{}.below, -1e9, checkOK},
+	}
+
+	for _, test := range tests {
+		if got := test.state(test.v); got != test.want {
+			t.Errorf("%s: %v is %s, want %s", test.name, test.v,
+				checkStateNames[got], checkStateNames[test.want])
+		}
+	}
+}
+
+// TestPerfData checks the performance data entries, with unset limits left
+// empty.
+func TestPerfData(t *testing.T) {
+	tests := []struct {
+		t     threshold
+		label string
+		v     float64
+		want  string
+	}{
+		{
+			t: threshold{
+				warn: 2, crit: 5, hasWarn: true, hasCrit: true,
+			},
+			label: "inactive",
+			v:     3,
+			want:  "inactive=3;2;5;0",
+		},
+		{
+			t:     threshold{crit: 10, hasCrit: true},
+			label: "block_age",
+			v:     12.5,
+			want:  "block_age=12.5;;10;0",
+		},
+		{
+			t:     threshold{warn: 1000000, hasWarn: true},
+			label: "wallet_balance",
+			v:     2500000,
+			want:  "wallet_balance=2500000;1000000;;0",
+		},
+		{
+			label: "channels",
+			v:     0,
+			want:  "channels=0;;;0",
+		},
+	}
+
+	for _, test := range tests {
+		if got := test.t.perfData(test.label, test.v); got != test.want {
+			t.Errorf("got %q, want %q", got, test.want)
+		}
+	}
+}
+
+// TestParseVersion checks the lnd versions as GetInfo and --min-version
+// give them.
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		want    [3]int
+		err     bool
+	}{
+		{
+			version: "0.19.3-beta commit=v0.19.3-beta",
+			want:    [3]int{0, 19, 3},
+		},
+		{version: "0.18.0-beta.rc2", want: [3]int{0, 18, 0}},
+		{version: "v0.17.5", want: [3]int{0, 17, 5}},
+		{version: "0.18", want: [3]int{0, 18, 0}},
+		{version: "1", want: [3]int{1, 0, 0}},
+		{version: "", err: true},
+		{version: "   ", err: true},
+		{version: "0.19.3.1", err: true},
+		{version: "0.x.3", err: true},
+		{version: "beta", err: true},
+	}
+
+	for _, test := range tests {
+		got, err := parseVersion(test.version)
+		switch {
+		case test.err && err == nil:
+			t.Errorf("%q was accepted as %v", test.version, got)
+
+		case !test.err && err != nil:
+			t.Errorf("%q: %v", test.version, err)
+
+		case !test.err && got != test.want:
+			t.Errorf("%q parsed as %v, want %v", test.version, got,
+				test.want)
+		}
+	}
+}
+
+// TestExitCode checks that lnb check fails with UNKNOWN wherever the
+// command is among the global flags.
+func TestExitCode(t *testing.T) {
+	app := newApp()
+
+	tests := []struct {
+		args []string
+		want int
+	}{
+		{[]string{"lnb", "check"}, checkUnknown},
+		{[]string{"lnb", "--configfile", "/nonexistent", "check"},
+			checkUnknown},
+		{[]string{"lnb", "--backend=bogus", "check", "--inactive",
+			"x"}, checkUnknown},
+		{[]string{"lnb", "--timings", "check"}, checkUnknown},
+		{[]string{"lnb", "--unit", "check", "list", "channels"}, 1},
+		{[]string{"lnb", "list", "channels"}, 1},
+		{[]string{"lnb", "--configfile", "/nonexistent"}, 1},
+	}
+
+	for _, test := range tests {
+		if got := exitCode(app, test.args); got != test.want {
+			t.Errorf("%v exits with %d, want %d", test.args, got,
+				test.want)
+		}
+	}
+}
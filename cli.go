@@ -81,6 +81,50 @@ var listCommand = cli.Command{
 					Usage: "(optional) only display channels for a peer " +
 						"with a 66-byte hex-encoded pubkey",
 				},
+				&cli.BoolFlag{
+					Name: "alias",
+					Usage: "show peer aliases instead of public " +
+						"keys in the peer column, the alias " +
+						"column always has them",
+				},
+				&cli.IntFlag{
+					Name:  "drain-days",
+					Value: defaultDrainDays,
+					Usage: "flag the channels whose eta_empty or " +
+						"eta_full forecast is at most this many days",
+				},
+				&cli.BoolFlag{
+					Name: "trend",
+					Usage: "add a sparkline of each channel's ratio " +
+						"over the last 30 days of snapshots",
+				},
+				&cli.StringFlag{
+					Name: "where",
+					Usage: "only list channels matching the expression, " +
+						"e.g. 'ratio < 20 && active && month_fee_sat > 100'",
+				},
+				&cli.StringFlag{
+					Name:  "columns",
+					Value: defaultChannelColumns,
+					Usage: "the comma separated columns to show, " +
+						"any of " + channelColumnNames(),
+				},
+				&cli.StringFlag{
+					Name: "sort",
+					Usage: "the comma separated columns to sort by, " +
+						"each optionally followed by :asc or :desc, " +
+						"e.g. month_fee:desc,ratio:asc",
+					DefaultText: "id:desc",
+				},
+				&cli.IntFlag{
+					Name:  "limit",
+					Usage: "only list the first n channels after sorting",
+				},
+				&cli.StringFlag{
+					Name:  "output",
+					Value: outputTable,
+					Usage: "the output format: table, csv or json",
+				},
 			},
 		},
 		{
@@ -90,6 +134,22 @@ var listCommand = cli.Command{
 			Category:  "list",
 			Action:    listContracts,
 			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name: "since",
+					Usage: "the starting time for the query, e.g. 7d, " +
+						"yesterday, 2026-09-01 or an RFC3339 timestamp",
+				},
+				&cli.StringFlag{
+					Name: "until",
+					Usage: "the end time for the query, in the same " +
+						"formats as --since",
+				},
+				&cli.StringFlag{
+					Name:  "tz",
+					Value: "Local",
+					Usage: "the time zone dates are given and times are " +
+						"shown in, e.g. UTC or Europe/Berlin",
+				},
 				&cli.Int64Flag{
 					Name: "start_time",
 					Usage: "the starting time for the query, expressed in " +
@@ -109,10 +169,575 @@ var listCommand = cli.Command{
 					Usage:       "the max number of events to return",
 					DefaultText: "100",
 				},
-				&cli.StringFlag{
+				&cli.StringSliceFlag{
 					Name: "channel",
 					Usage: "(optional) only display contracts for a channel " +
-						"with id in bbbbbb:iiii:p format",
+						"given as bbbbbb:iiii:p, bbbbbbxiiiixp, numeric id " +
+						"or txid:output, may be repeated",
+				},
+				&cli.StringSliceFlag{
+					Name: "peer",
+					Usage: "(optional) only display contracts for the " +
+						"channels with a peer, may be repeated",
+				},
+				&cli.BoolFlag{
+					Name: "in",
+					Usage: "only display contracts which came in through " +
+						"the selected channels",
+				},
+				&cli.BoolFlag{
+					Name: "out",
+					Usage: "only display contracts which went out through " +
+						"the selected channels",
+				},
+				&cli.StringFlag{
+					Name: "where",
+					Usage: "only display contracts matching the expression, " +
+						"e.g. 'amount_in > 500000 && fee_msat > 1000'",
+				},
+			},
+		},
+		{
+			Name:     "rebalances",
+			Usage:    "List circular payments from the payment history.",
+			Category: "list",
+			Action:   listRebalances,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "since",
+					Value: "30d",
+					Usage: "the starting time for the query, e.g. 7d, " +
+						"yesterday, 2026-09-01 or an RFC3339 timestamp",
+				},
+				&cli.StringFlag{
+					Name: "until",
+					Usage: "the end time for the query, in the same " +
+						"formats as --since",
+				},
+				&cli.StringFlag{
+					Name:  "tz",
+					Value: "Local",
+					Usage: "the time zone dates are given and times are " +
+						"shown in, e.g. UTC or Europe/Berlin",
+				},
+				&cli.StringSliceFlag{
+					Name: "channel",
+					Usage: "(optional) only list rebalances into or out " +
+						"of a channel, may be repeated",
+				},
+			},
+		},
+	},
+}
+
+var snapshotCommand = cli.Command{
+	Name:  "snapshot",
+	Usage: "Record the balances of all channels for report history.",
+	Description: "Appends the balances, active flag and totals of every " +
+		"open channel to the store in --lnbdir. Run it regularly, " +
+		"e.g. from cron, to follow how balances evolve.",
+	Action: takeSnapshot,
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "quiet",
+			Usage: "don't print anything unless there is an error",
+		},
+	},
+}
+
+var checkCommand = cli.Command{
+	Name:  "check",
+	Usage: "Check the health of the node for a monitoring system.",
+	Description: "Prints a one-line summary with performance data and " +
+		"exits 0 for OK, 1 for WARNING, 2 for CRITICAL and 3 for " +
+		"UNKNOWN, like a Nagios plugin. Not being synced to chain " +
+		"is critical, not being synced to graph a warning. Limits " +
+		"are given as warn,crit, either of which may be left out.",
+	Action: checkNode,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "inactive",
+			Value: "2,5",
+			Usage: "the limits of inactive channels, alerting above " +
+				"them",
+		},
+		&cli.StringFlag{
+			Name:  "pending-force-closes",
+			Value: "0,2",
+			Usage: "the limits of pending force closes, alerting " +
+				"above them",
+		},
+		&cli.StringFlag{
+			Name: "wallet-balance",
+			Usage: "the limits of the confirmed wallet balance in " +
+				"sat, alerting below them",
+			DefaultText: "off",
+		},
+		&cli.StringFlag{
+			Name:  "block-age",
+			Value: "60,120",
+			Usage: "the limits of the minutes since the best block, " +
+				"alerting above them",
+		},
+		&cli.StringFlag{
+			Name: "min-version",
+			Usage: "warn if lnd is older than this version, e.g. " +
+				"0.18.5",
+			DefaultText: "off",
+		},
+	},
+}
+
+var recordCommand = cli.Command{
+	Name:      "record",
+	Usage:     "Record the node's responses as fixtures for --backend.",
+	ArgsUsage: "dir",
+	Description: "Saves the responses of the calls lnb makes as JSON " +
+		"files in dir. Run any command with --backend fixture:dir to " +
+		"serve it from the recording instead of a live node.",
+	Action: recordFixtures,
+	Flags: []cli.Flag{
+		&cli.IntFlag{
+			Name:  "days",
+			Value: 30,
+			Usage: "the number of days of forwarding history to record",
+		},
+		&cli.StringSliceFlag{
+			Name: "redact",
+			Usage: "replace identifying data in the recording: " +
+				"pubkeys, aliases, chanpoints, addresses or all",
+		},
+	},
+}
+
+var reportCommand = cli.Command{
+	Name:  "report",
+	Usage: "Analyze the history of the node.",
+	Subcommands: []*cli.Command{
+		{
+			Name:  "roi",
+			Usage: "Show the profitability of every open and closed channel.",
+			Description: "Fees earned by forwards leaving through a " +
+				"channel, minus the rebalance fees of refilling it " +
+				"and the on-chain fees of opening and closing it, " +
+				"annualized over the capital locked in it.",
+			Category: "report",
+			Action:   reportROI,
+			Flags: []cli.Flag{
+				&cli.BoolFlag{
+					Name:  "alias",
+					Usage: "show peer aliases instead of public keys",
+				},
+			},
+		},
+		{
+			Name:  "profit",
+			Usage: "Show the net routing profit per period.",
+			Description: "Forwards, routed volume, fees earned, " +
+				"rebalance fees and on-chain fees spent and the " +
+				"net profit of each period, with its running total.",
+			Category: "report",
+			Action:   reportProfit,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "period",
+					Value: periodDaily,
+					Usage: "the length of the periods: daily, weekly " +
+						"or monthly",
+				},
+				&cli.StringFlag{
+					Name:  "since",
+					Value: "30d",
+					Usage: "the start of the report, e.g. 90d, " +
+						"2026-01-01 or an RFC3339 timestamp",
+				},
+				&cli.StringFlag{
+					Name: "until",
+					Usage: "the end of the report, in the same " +
+						"formats as --since",
+				},
+				&cli.StringFlag{
+					Name:  "tz",
+					Value: "Local",
+					Usage: "the time zone periods start in, e.g. UTC " +
+						"or Europe/Berlin",
+				},
+			},
+		},
+		{
+			Name:  "flows",
+			Usage: "Show which channels or peers forward to which.",
+			Description: "Forwards summed up per pair of incoming and " +
+				"outgoing channel or peer, busiest first, or as a " +
+				"matrix of the volume between them.",
+			Category: "report",
+			Action:   reportFlows,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "by",
+					Value: flowsByChannel,
+					Usage: "group forwards by channel or by peer",
+				},
+				&cli.StringFlag{
+					Name:  "since",
+					Value: "30d",
+					Usage: "the start of the window, e.g. 7d, " +
+						"2026-01-01 or an RFC3339 timestamp",
+				},
+				&cli.StringFlag{
+					Name: "until",
+					Usage: "the end of the window, in the same " +
+						"formats as --since",
+				},
+				&cli.StringFlag{
+					Name:  "tz",
+					Value: "Local",
+					Usage: "the time zone dates are given in, e.g. " +
+						"UTC or Europe/Berlin",
+				},
+				&cli.BoolFlag{
+					Name: "matrix",
+					Usage: "show a heatmap of the volume from each " +
+						"incoming to each outgoing channel or peer",
+				},
+				&cli.IntFlag{
+					Name: "limit",
+					Usage: "the number of pairs to show, or of " +
+						"channels or peers per matrix axis",
+					DefaultText: "all pairs, 10 per axis",
+				},
+				&cli.BoolFlag{
+					Name:  "alias",
+					Usage: "show peer aliases instead of public keys",
+				},
+			},
+		},
+		{
+			Name:  "idle",
+			Usage: "Show the channels which didn't forward for a while.",
+			Description: "Open channels without forwards in either " +
+				"direction, ranked as close candidates by the " +
+				"balance closing them would give back.",
+			Category: "report",
+			Action:   reportIdle,
+			Flags: []cli.Flag{
+				&cli.IntFlag{
+					Name:  "days",
+					Value: 30,
+					Usage: "the number of days without forwards",
+				},
+				&cli.Float64Flag{
+					Name:  "sat-per-vbyte",
+					Usage: "the fee rate to estimate close fees at",
+					DefaultText: "the rate of each commitment " +
+						"transaction",
+				},
+				&cli.BoolFlag{
+					Name:  "alias",
+					Usage: "show peer aliases instead of public keys",
+				},
+			},
+		},
+		{
+			Name:  "history",
+			Usage: "Show how the balances of channels evolved.",
+			Description: "The recorded snapshots of each channel with " +
+				"a sparkline of its ratio, see lnb snapshot.",
+			Category: "report",
+			Action:   reportHistory,
+			Flags: []cli.Flag{
+				&cli.StringSliceFlag{
+					Name: "channel",
+					Usage: "the channel to show, may be " +
+						"repeated",
+				},
+				&cli.StringFlag{
+					Name:  "since",
+					Value: "30d",
+					Usage: "the start of the history, e.g. 7d, " +
+						"2026-01-01 or an RFC3339 timestamp",
+				},
+				&cli.StringFlag{
+					Name: "until",
+					Usage: "the end of the history, in the same " +
+						"formats as --since",
+				},
+				&cli.StringFlag{
+					Name:  "tz",
+					Value: "Local",
+					Usage: "the time zone dates are given and times are " +
+						"shown in, e.g. UTC or Europe/Berlin",
+				},
+			},
+		},
+		{
+			Name:  "htlc-sizes",
+			Usage: "Show the distribution of forwarded amounts.",
+			Description: "The count, minimum, percentiles and maximum " +
+				"of the amounts forwarded per channel and " +
+				"direction, with the fee ppm they earned, or a " +
+				"histogram of them.",
+			Category: "report",
+			Action:   reportHTLCSizes,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "since",
+					Value: "30d",
+					Usage: "the start of the window, e.g. 7d, " +
+						"2026-01-01 or an RFC3339 timestamp",
+				},
+				&cli.StringFlag{
+					Name: "until",
+					Usage: "the end of the window, in the same " +
+						"formats as --since",
+				},
+				&cli.StringFlag{
+					Name:  "tz",
+					Value: "Local",
+					Usage: "the time zone dates are given in, e.g. " +
+						"UTC or Europe/Berlin",
+				},
+				&cli.StringSliceFlag{
+					Name: "channel",
+					Usage: "(optional) only show forwards into or out " +
+						"of a channel, may be repeated",
+				},
+				&cli.BoolFlag{
+					Name: "histogram",
+					Usage: "show a histogram of the amounts, of all " +
+						"forwards or per channel and direction",
+				},
+			},
+		},
+		{
+			Name:  "activity",
+			Usage: "Show when forwards happen by weekday and hour.",
+			Description: "A heatmap of the count, volume or fees of " +
+				"the forwards by weekday and hour of the day, of " +
+				"the whole node or of some channels.",
+			Category: "report",
+			Action:   reportActivity,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "metric",
+					Value: metricCount,
+					Usage: "what to map: count, volume or fees",
+				},
+				&cli.StringFlag{
+					Name:  "since",
+					Value: "30d",
+					Usage: "the start of the window, e.g. 90d, " +
+						"2026-01-01 or an RFC3339 timestamp",
+				},
+				&cli.StringFlag{
+					Name: "until",
+					Usage: "the end of the window, in the same " +
+						"formats as --since",
+				},
+				&cli.StringFlag{
+					Name:  "tz",
+					Value: "Local",
+					Usage: "the time zone of the weekdays and hours, " +
+						"e.g. UTC or Europe/Berlin",
+				},
+				&cli.StringSliceFlag{
+					Name: "channel",
+					Usage: "(optional) only map forwards into or out " +
+						"of a channel, may be repeated",
+				},
+			},
+		},
+		{
+			Name:  "htlc-risk",
+			Usage: "Show in-flight HTLCs close to their force close.",
+			Description: "Lists the HTLCs pending on open channels " +
+				"by the blocks left until lnd has to force " +
+				"close the channel to resolve them, flagging " +
+				"those within --blocks, and the HTLC slots each " +
+				"channel uses out of the ones its peer and we " +
+				"accept.",
+			Category: "report",
+			Action:   reportHTLCRisk,
+			Flags: []cli.Flag{
+				&cli.Int64Flag{
+					Name:  "blocks",
+					Value: defaultRiskBlocks,
+					Usage: "flag HTLCs whose force close is at " +
+						"most this many blocks away",
+				},
+				&cli.StringSliceFlag{
+					Name: "channel",
+					Usage: "(optional) only show HTLCs of a " +
+						"channel, may be repeated",
+				},
+				&cli.BoolFlag{
+					Name:  "alias",
+					Usage: "look up the alias of each peer",
+				},
+			},
+		},
+		{
+			Name:  "jamming",
+			Usage: "Score peers for holding HTLCs or filling slots.",
+			Description: "Rates each peer by the share of the " +
+				"forwards through it which were held longer " +
+				"than --hold, from the HTLC events lnb watch " +
+				"htlcs recorded, and by the largest share of " +
+				"HTLC slots in use on its channels now. Peers " +
+				"scoring --threshold or more are flagged as " +
+				"suspected of jamming.",
+			Category: "report",
+			Action:   reportJamming,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "since",
+					Value: "7d",
+					Usage: "the start of the window, e.g. 30d, " +
+						"2026-01-01 or an RFC3339 timestamp",
+				},
+				&cli.StringFlag{
+					Name: "until",
+					Usage: "the end of the window, in the same " +
+						"formats as --since",
+				},
+				&cli.StringFlag{
+					Name:  "tz",
+					Value: "Local",
+					Usage: "the time zone dates are given in, e.g. " +
+						"UTC or Europe/Berlin",
+				},
+				&cli.DurationFlag{
+					Name:  "hold",
+					Value: defaultLongHold,
+					Usage: "the hold time above which a forward " +
+						"counts as held for long",
+				},
+				&cli.Float64Flag{
+					Name:  "threshold",
+					Value: defaultJamThreshold,
+					Usage: "the score from 0 to 100 from which a " +
+						"peer is suspected",
+				},
+				&cli.BoolFlag{
+					Name:  "alias",
+					Usage: "look up the alias of each peer",
+				},
+			},
+		},
+		{
+			Name:  "failures",
+			Usage: "Show which channels forwards fail on, and why.",
+			Description: "Sums up the failed forwards per outgoing " +
+				"channel and per reason, from the HTLC events " +
+				"lnb watch htlcs recorded.",
+			Category: "report",
+			Action:   reportFailures,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "since",
+					Value: "7d",
+					Usage: "the start of the window, e.g. 30d, " +
+						"2026-01-01 or an RFC3339 timestamp",
+				},
+				&cli.StringFlag{
+					Name: "until",
+					Usage: "the end of the window, in the same " +
+						"formats as --since",
+				},
+				&cli.StringFlag{
+					Name:  "tz",
+					Value: "Local",
+					Usage: "the time zone dates are given in, e.g. " +
+						"UTC or Europe/Berlin",
+				},
+			},
+		},
+	},
+}
+
+var watchCommand = cli.Command{
+	Name:  "watch",
+	Usage: "htlcs, channels",
+	Subcommands: []*cli.Command{
+		{
+			Name:  "htlcs",
+			Usage: "Print HTLC events as they happen.",
+			Description: "Follows the HTLC event stream of the " +
+				"router and prints every forward, settle, link " +
+				"failure and forward failure with its channels, " +
+				"amount, fee and failure reason until " +
+				"interrupted. The events are recorded in " +
+				"--lnbdir for lnb report failures.",
+			Category: "watch",
+			Action:   watchHTLCs,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "tz",
+					Value: "Local",
+					Usage: "the time zone times are shown in, e.g. " +
+						"UTC or Europe/Berlin",
+				},
+				&cli.BoolFlag{
+					Name: "no-record",
+					Usage: "only print the events, don't record " +
+						"them, implied with a fixture backend",
+				},
+			},
+		},
+		{
+			Name:  "channels",
+			Usage: "Print channel events and alert on them.",
+			Description: "Follows the channel events of lnd and prints " +
+				"every channel which is pending, opened, closed, " +
+				"becomes active or inactive until interrupted. " +
+				"Channels breaking one of the rules are alerted " +
+				"on once, by posting the alert as JSON to " +
+				"--webhook and feeding it to --exec on stdin, " +
+				"and again after they recovered.",
+			Category: "watch",
+			Action:   watchChannels,
+			Flags: []cli.Flag{
+				&cli.IntFlag{
+					Name: "inactive-minutes",
+					Usage: "alert when a channel is inactive " +
+						"for more than this many minutes",
+					DefaultText: "off",
+				},
+				&cli.BoolFlag{
+					Name: "force-close",
+					Usage: "alert when a channel is force " +
+						"closed, by either side",
+				},
+				&cli.Float64Flag{
+					Name: "min-ratio",
+					Usage: "alert when the local ratio of a " +
+						"channel drops below this percentage",
+					DefaultText: "off",
+				},
+				&cli.DurationFlag{
+					Name:  "interval",
+					Value: defaultCheckInterval,
+					Usage: "how often the balances are polled " +
+						"and the rules checked",
+				},
+				&cli.StringFlag{
+					Name:  "webhook",
+					Usage: "an http(s) URL alerts are posted to",
+				},
+				&cli.StringFlag{
+					Name: "exec",
+					Usage: "a script which is run per alert with " +
+						"the alert on stdin",
+				},
+				&cli.BoolFlag{
+					Name:  "alias",
+					Usage: "look up the alias of each peer",
+				},
+				&cli.StringFlag{
+					Name:  "tz",
+					Value: "Local",
+					Usage: "the time zone times are shown in, e.g. " +
+						"UTC or Europe/Berlin",
 				},
 			},
 		},
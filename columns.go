@@ -0,0 +1,737 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/shopspring/decimal"
+	"github.com/urfave/cli/v2"
+)
+
+// The output formats of list channels, see --output.
+const (
+	outputTable = "table"
+	outputCSV   = "csv"
+	outputJSON  = "json"
+)
+
+// defaultChannelColumns are the columns list channels shows unless --columns
+// is given.
+const defaultChannelColumns = "id,peer,capacity,local,remote,ratio,day_in," +
+	"day_out,month_in,month_out,month_fee,month_net_fee,total_in,total_out," +
+	"efficiency,eta_empty,eta_full"
+
+// channelColumn is a column of list channels. The table, CSV and JSON outputs
+// are all rendered from the same columns, so they always agree.
+type channelColumn struct {
+	name   string
+	header string
+
+	// left aligns the column to the left in tables.
+	left bool
+
+	// value returns the value of the column used for sorting, CSV and
+	// JSON. It is an int64, a float64, a string or a bool, or nil if the
+	// channel has no value.
+	value func(r *ChannelRow, f *rowFormat) interface{}
+
+	// text formats the value for the table. If it is nil the value is
+	// printed as is.
+	text func(r *ChannelRow, f *rowFormat) string
+
+	// total formats the column of the total row of the table. If it is
+	// nil the column is left empty.
+	total func(t *TotalChannels, f *rowFormat) string
+
+	// fiat is set for the columns which need --fiat.
+	fiat bool
+
+	// rebalances is set for the columns which need the payment history.
+	rebalances bool
+
+	// snapshots is set for the columns which need the recorded
+	// snapshots.
+	snapshots bool
+
+	// aliases is set for the columns which need the aliases of the
+	// peers.
+	aliases bool
+}
+
+// rowFormat holds the options which control how values are printed.
+type rowFormat struct {
+	chanFmt chanFormat
+	unit    amountUnit
+
+	// drainDays is the drain forecast below which channels are flagged.
+	drainDays int
+
+	// peerAliases prints the peer column as the alias, see --alias.
+	peerAliases bool
+}
+
+// amountColumn returns a column of an amount which is printed in the --unit
+// and summed up in the total row.
+func amountColumn(name, header string,
+	get func(r *ChannelRow) lnwire.MilliSatoshi,
+	total func(t *TotalChannels) lnwire.MilliSatoshi) channelColumn {
+
+	return channelColumn{
+		name:   name,
+		header: header,
+		value: func(r *ChannelRow, f *rowFormat) interface{} {
+			return f.unit.value(get(r))
+		},
+		text: func(r *ChannelRow, f *rowFormat) string {
+			return f.unit.format(get(r))
+		},
+		total: func(t *TotalChannels, f *rowFormat) string {
+			return f.unit.format(total(t))
+		},
+	}
+}
+
+// withRebalances marks a column as needing the payment history.
+func withRebalances(c channelColumn) channelColumn {
+	c.rebalances = true
+	return c
+}
+
+// percentColumn returns a column of a percentage which is rounded in the
+// table.
+func percentColumn(name, header string, get func(r *ChannelRow) float64,
+	total func(t *TotalChannels) float64) channelColumn {
+
+	return channelColumn{
+		name:   name,
+		header: header,
+		value: func(r *ChannelRow, _ *rowFormat) interface{} {
+			return get(r)
+		},
+		text: func(r *ChannelRow, _ *rowFormat) string {
+			return fmt.Sprintf("%d%%", int64(math.Round(get(r))))
+		},
+		total: func(t *TotalChannels, _ *rowFormat) string {
+			return fmt.Sprintf("%d%%", int64(math.Round(total(t))))
+		},
+	}
+}
+
+// channelColumns is the registry of all columns list channels can show, in
+// the order --help lists them.
+var channelColumns = []channelColumn{
+	{
+		name:   "id",
+		header: "Channel ID",
+		// The numeric id sorts channels by their funding block, and
+		// doesn't change with --chan-format.
+		value: func(r *ChannelRow, _ *rowFormat) interface{} {
+			return int64(r.ChannelID)
+		},
+		text: func(r *ChannelRow, f *rowFormat) string {
+			return f.chanFmt.format(r.ChannelID)
+		},
+	},
+	{
+		name:   "peer",
+		header: "Peer",
+		left:   true,
+		value: func(r *ChannelRow, _ *rowFormat) interface{} {
+			return hex.EncodeToString(r.PubKeyBytes[:])
+		},
+		text: func(r *ChannelRow, f *rowFormat) string {
+			if f.peerAliases && r.Alias != "" {
+				return truncate(r.Alias, 10)
+			}
+
+			return hex.EncodeToString(r.PubKeyBytes[:4])
+		},
+	},
+	{
+		name:    "alias",
+		header:  "Alias",
+		left:    true,
+		aliases: true,
+		value: func(r *ChannelRow, _ *rowFormat) interface{} {
+			return r.Alias
+		},
+		text: func(r *ChannelRow, _ *rowFormat) string {
+			return truncate(r.Alias, 20)
+		},
+	},
+	{
+		name:   "active",
+		header: "Active",
+		value: func(r *ChannelRow, _ *rowFormat) interface{} {
+			return r.Active
+		},
+	},
+	{
+		name:   "private",
+		header: "Private",
+		value: func(r *ChannelRow, _ *rowFormat) interface{} {
+			return r.Private
+		},
+	},
+	amountColumn("capacity", "Capacity",
+		func(r *ChannelRow) lnwire.MilliSatoshi {
+			return sat(r.Capacity)
+		},
+		func(t *TotalChannels) lnwire.MilliSatoshi {
+			return sat(t.Capacity)
+		},
+	),
+	amountColumn("local", "Local",
+		func(r *ChannelRow) lnwire.MilliSatoshi {
+			return sat(r.LocalBalance)
+		},
+		func(t *TotalChannels) lnwire.MilliSatoshi {
+			return sat(t.LocalBalance)
+		},
+	),
+	amountColumn("remote", "Remote",
+		func(r *ChannelRow) lnwire.MilliSatoshi {
+			return sat(r.RemoteBalance)
+		},
+		func(t *TotalChannels) lnwire.MilliSatoshi {
+			return sat(t.RemoteBalance)
+		},
+	),
+	amountColumn("commit_fee", "Commit Fee",
+		func(r *ChannelRow) lnwire.MilliSatoshi {
+			return sat(r.CommitFee)
+		},
+		func(t *TotalChannels) lnwire.MilliSatoshi {
+			return sat(t.CommitFee)
+		},
+	),
+	percentColumn("ratio", "Ratio",
+		func(r *ChannelRow) float64 { return r.Ratio },
+		func(t *TotalChannels) float64 { return t.Ratio },
+	),
+	amountColumn("day_in", "Day In",
+		func(r *ChannelRow) lnwire.MilliSatoshi {
+			return sat(r.HTLC.Day.AmountSatIn)
+		},
+		func(t *TotalChannels) lnwire.MilliSatoshi {
+			return sat(t.DayAmountSatIn)
+		},
+	),
+	amountColumn("day_out", "Day Out",
+		func(r *ChannelRow) lnwire.MilliSatoshi {
+			return sat(r.HTLC.Day.AmountSatOut)
+		},
+		func(t *TotalChannels) lnwire.MilliSatoshi {
+			return sat(t.DayAmountSatOut)
+		},
+	),
+	amountColumn("month_in", "Month In",
+		func(r *ChannelRow) lnwire.MilliSatoshi {
+			return sat(r.HTLC.Month.AmountSatIn)
+		},
+		func(t *TotalChannels) lnwire.MilliSatoshi {
+			return sat(t.MonthAmountSatIn)
+		},
+	),
+	amountColumn("month_out", "Month Out",
+		func(r *ChannelRow) lnwire.MilliSatoshi {
+			return sat(r.HTLC.Month.AmountSatOut)
+		},
+		func(t *TotalChannels) lnwire.MilliSatoshi {
+			return sat(t.MonthAmountSatOut)
+		},
+	),
+	amountColumn("month_fee", "Mon Fee",
+		func(r *ChannelRow) lnwire.MilliSatoshi {
+			return r.HTLC.Month.FeeMsat
+		},
+		func(t *TotalChannels) lnwire.MilliSatoshi {
+			return lnwire.MilliSatoshi(t.MonthFee.Mul(msatPerSat).IntPart())
+		},
+	),
+	{
+		name:   "month_fee_fiat",
+		header: "Mon Fee Fiat",
+		value: func(r *ChannelRow, _ *rowFormat) interface{} {
+			return r.HTLC.Month.FeeFiat.InexactFloat64()
+		},
+		text: func(r *ChannelRow, _ *rowFormat) string {
+			return formatFiat(r.HTLC.Month.FeeFiat)
+		},
+		total: func(t *TotalChannels, _ *rowFormat) string {
+			return formatFiat(t.MonthFeeFiat)
+		},
+		fiat: true,
+	},
+	withRebalances(amountColumn("month_rebalance_fee", "Mon Rebal",
+		func(r *ChannelRow) lnwire.MilliSatoshi {
+			return r.MonthRebalanceFee
+		},
+		func(t *TotalChannels) lnwire.MilliSatoshi {
+			return t.MonthRebalanceFee
+		},
+	)),
+	{
+		name:   "month_net_fee",
+		header: "Mon Net",
+		value: func(r *ChannelRow, f *rowFormat) interface{} {
+			return f.unit.signedValue(r.monthNetFee())
+		},
+		text: func(r *ChannelRow, f *rowFormat) string {
+			return f.unit.formatSigned(r.monthNetFee())
+		},
+		total: func(t *TotalChannels, f *rowFormat) string {
+			fee := t.MonthFee.Mul(msatPerSat).IntPart()
+			return f.unit.formatSigned(
+				fee - int64(t.MonthRebalanceFee),
+			)
+		},
+		rebalances: true,
+	},
+	{
+		name:   "trend",
+		header: "Trend",
+		left:   true,
+		value: func(r *ChannelRow, _ *rowFormat) interface{} {
+			return r.Trend
+		},
+		snapshots: true,
+	},
+	amountColumn("total_in", "Total In",
+		func(r *ChannelRow) lnwire.MilliSatoshi {
+			return sat(r.TotalReceived)
+		},
+		func(t *TotalChannels) lnwire.MilliSatoshi {
+			return sat(t.AmountIn)
+		},
+	),
+	amountColumn("total_out", "Total Out",
+		func(r *ChannelRow) lnwire.MilliSatoshi {
+			return sat(r.TotalSent)
+		},
+		func(t *TotalChannels) lnwire.MilliSatoshi {
+			return sat(t.AmountOut)
+		},
+	),
+	percentColumn("efficiency", "Effcy",
+		func(r *ChannelRow) float64 { return r.Efficiency },
+		func(t *TotalChannels) float64 { return t.Efficiency },
+	),
+	etaColumn("eta_empty", "ETA Empty", (*ChannelRow).etaEmpty),
+	etaColumn("eta_full", "ETA Full", (*ChannelRow).etaFull),
+}
+
+// formatFee prints a fee in satoshis with millisatoshi precision if it is
+// small, rounded to whole satoshis otherwise.
+func formatFee(fee decimal.Decimal) string {
+	switch {
+	case fee.IsZero():
+		return "0"
+	case fee.LessThan(decimal.NewFromInt(100)):
+		return fee.StringFixed(3)
+	default:
+		return fee.StringFixedBank(0)
+	}
+}
+
+// lookupColumn returns the column with the given name.
+func lookupColumn(name string) (*channelColumn, error) {
+	for i := range channelColumns {
+		if channelColumns[i].name == name {
+			return &channelColumns[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown column %q, known columns are %s", name,
+		channelColumnNames())
+}
+
+// channelColumnNames returns the names of all columns, comma separated.
+func channelColumnNames() string {
+	names := make([]string, 0, len(channelColumns))
+	for _, c := range channelColumns {
+		names = append(names, c.name)
+	}
+
+	return strings.Join(names, ", ")
+}
+
+// parseColumns parses a comma separated list of column names.
+func parseColumns(spec string) ([]*channelColumn, error) {
+	var columns []*channelColumn
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		c, err := lookupColumn(name)
+		if err != nil {
+			return nil, err
+		}
+		columns = append(columns, c)
+	}
+
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("no columns selected")
+	}
+
+	return columns, nil
+}
+
+// sortKey is one column of a --sort order.
+type sortKey struct {
+	column *channelColumn
+	desc   bool
+}
+
+// parseSortKeys parses a --sort order like month_fee:desc,ratio:asc. Keys
+// without a direction sort ascending.
+func parseSortKeys(spec string) ([]sortKey, error) {
+	var keys []sortKey
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		name, dir, _ := strings.Cut(part, ":")
+		c, err := lookupColumn(name)
+		if err != nil {
+			return nil, err
+		}
+
+		key := sortKey{column: c}
+		switch dir {
+		case "", "asc":
+		case "desc":
+			key.desc = true
+		default:
+			return nil, fmt.Errorf("invalid sort direction %q of %s, "+
+				"should be asc or desc", dir, name)
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// compareValues compares two values of the same column, returning -1, 0
+// or 1.
+func compareValues(a, b interface{}) int {
+	switch a := a.(type) {
+	case int64:
+		b := b.(int64)
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+
+	case float64:
+		b := b.(float64)
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+
+	case string:
+		return strings.Compare(a, b.(string))
+
+	case bool:
+		switch b := b.(bool); {
+		case !a && b:
+			return -1
+		case a && !b:
+			return 1
+		}
+	}
+
+	return 0
+}
+
+// sortChannelRows sorts the rows by the keys, keeping the order of rows
+// which compare equal.
+func sortChannelRows(rows []ChannelRow, keys []sortKey, f *rowFormat) {
+	sort.SliceStable(rows, func(i, j int) bool {
+		for _, k := range keys {
+			a, b := k.column.value(&rows[i], f),
+				k.column.value(&rows[j], f)
+
+			// Rows without a value go last in either direction.
+			if (a == nil) != (b == nil) {
+				return b == nil
+			}
+
+			c := compareValues(a, b)
+			if k.desc {
+				c = -c
+			}
+
+			if c != 0 {
+				return c < 0
+			}
+		}
+
+		return false
+	})
+}
+
+// channelView holds the --columns, --sort, --limit and --output options of
+// list channels.
+type channelView struct {
+	columns []*channelColumn
+	sort    []sortKey
+	limit   int
+	output  string
+	format  rowFormat
+}
+
+// getChannelView reads the view options of list channels.
+func getChannelView(ctx *cli.Context) (*channelView, error) {
+	chanFmt, err := getChanFormat(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	unit, err := getAmountUnit(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	spec := ctx.String("columns")
+	if !ctx.IsSet("columns") && ctx.String("fiat") != "" {
+		spec += ",month_fee_fiat"
+	}
+	if !ctx.IsSet("columns") && ctx.Bool("trend") {
+		spec += ",trend"
+	}
+
+	columns, err := parseColumns(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --columns: %w", err)
+	}
+	for _, c := range columns {
+		if c.fiat && ctx.String("fiat") == "" {
+			return nil, fmt.Errorf("column %s needs --fiat", c.name)
+		}
+	}
+
+	keys, err := parseSortKeys(ctx.String("sort"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid --sort: %w", err)
+	}
+
+	if ctx.Int("limit") < 0 {
+		return nil, fmt.Errorf("invalid --limit %d", ctx.Int("limit"))
+	}
+
+	if ctx.Int("drain-days") < 0 {
+		return nil, fmt.Errorf("invalid --drain-days %d",
+			ctx.Int("drain-days"))
+	}
+
+	switch ctx.String("output") {
+	case outputTable, outputCSV, outputJSON:
+	default:
+		return nil, fmt.Errorf("invalid --output %q, should be %s, %s "+
+			"or %s", ctx.String("output"), outputTable, outputCSV,
+			outputJSON)
+	}
+
+	return &channelView{
+		columns: columns,
+		sort:    keys,
+		limit:   ctx.Int("limit"),
+		output:  ctx.String("output"),
+		format: rowFormat{
+			chanFmt:     chanFmt,
+			unit:        unit,
+			drainDays:   ctx.Int("drain-days"),
+			peerAliases: ctx.Bool("alias"),
+		},
+	}, nil
+}
+
+// needsRebalances returns true if a shown or sorted column needs the
+// payment history.
+func (v *channelView) needsRebalances() bool {
+	for _, c := range v.columns {
+		if c.rebalances {
+			return true
+		}
+	}
+	for _, k := range v.sort {
+		if k.column.rebalances {
+			return true
+		}
+	}
+
+	return false
+}
+
+// needsSnapshots returns true if a shown or sorted column needs the
+// recorded snapshots.
+func (v *channelView) needsSnapshots() bool {
+	for _, c := range v.columns {
+		if c.snapshots {
+			return true
+		}
+	}
+	for _, k := range v.sort {
+		if k.column.snapshots {
+			return true
+		}
+	}
+
+	return false
+}
+
+// needsAliases returns true if a shown or sorted column needs the aliases
+// of the peers.
+func (v *channelView) needsAliases() bool {
+	for _, c := range v.columns {
+		if c.aliases {
+			return true
+		}
+	}
+	for _, k := range v.sort {
+		if k.column.aliases {
+			return true
+		}
+	}
+
+	return false
+}
+
+// apply sorts the rows and cuts them to the limit.
+func (v *channelView) apply(rows []ChannelRow) []ChannelRow {
+	sortChannelRows(rows, v.sort, &v.format)
+
+	if v.limit > 0 && len(rows) > v.limit {
+		rows = rows[:v.limit]
+	}
+
+	return rows
+}
+
+// print writes the rows in the selected output format.
+func (v *channelView) print(rows []ChannelRow) error {
+	switch v.output {
+	case outputCSV:
+		return printChannelsCSV(rows, v.columns, &v.format)
+
+	case outputJSON:
+		return printChannelsJSON(rows, v.columns, &v.format)
+
+	default:
+		printChannels(rows, v.columns, &v.format)
+		return nil
+	}
+}
+
+// formatValue prints a column value for CSV.
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// printChannelsCSV writes the rows as CSV with a header line of column
+// names.
+func printChannelsCSV(rows []ChannelRow, columns []*channelColumn,
+	f *rowFormat) error {
+
+	w := csv.NewWriter(os.Stdout)
+
+	record := make([]string, len(columns))
+	for i, c := range columns {
+		record[i] = c.name
+	}
+	if err := w.Write(record); err != nil {
+		return err
+	}
+
+	for i := range rows {
+		for j, c := range columns {
+			record[j] = formatValue(c.value(&rows[i], f))
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+
+	w.Flush()
+	return w.Error()
+}
+
+// orderedRow is a JSON object whose keys keep the order of the columns.
+type orderedRow struct {
+	columns []*channelColumn
+	values  []interface{}
+}
+
+// MarshalJSON encodes the row as an object keyed by column name.
+func (o orderedRow) MarshalJSON() ([]byte, error) {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, c := range o.columns {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+
+		key, err := json.Marshal(c.name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(o.values[i])
+		if err != nil {
+			return nil, err
+		}
+
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteByte('}')
+
+	return []byte(b.String()), nil
+}
+
+// printChannelsJSON writes the rows as a JSON array of objects.
+func printChannelsJSON(rows []ChannelRow, columns []*channelColumn,
+	f *rowFormat) error {
+
+	out := make([]orderedRow, 0, len(rows))
+	for i := range rows {
+		o := orderedRow{
+			columns: columns,
+			values:  make([]interface{}, len(columns)),
+		}
+		for j, c := range columns {
+			o.values[j] = c.value(&rows[i], f)
+		}
+		out = append(out, o)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "    ")
+	return encoder.Encode(out)
+}
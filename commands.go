@@ -20,6 +20,7 @@ import (
 	"github.com/lightningnetwork/lnd/routing/route"
 	"github.com/shopspring/decimal"
 	"github.com/urfave/cli/v2"
+	"golang.org/x/sync/errgroup"
 )
 
 // SumHTLC contains all forwarding amounts and fees for all channells
@@ -31,16 +32,19 @@ type ChanHTLC struct {
 		AmountSatIn  btcutil.Amount
 		AmountSatOut btcutil.Amount
 		FeeMsat      lnwire.MilliSatoshi
+		FeeFiat      decimal.Decimal
 	}
 	Week struct {
 		AmountSatIn  btcutil.Amount
 		AmountSatOut btcutil.Amount
 		FeeMsat      lnwire.MilliSatoshi
+		FeeFiat      decimal.Decimal
 	}
 	Month struct {
 		AmountSatIn  btcutil.Amount
 		AmountSatOut btcutil.Amount
 		FeeMsat      lnwire.MilliSatoshi
+		FeeFiat      decimal.Decimal
 	}
 }
 
@@ -67,10 +71,37 @@ type TotalChannels struct {
 	DayFee   decimal.Decimal
 	WeekFee  decimal.Decimal
 	MonthFee decimal.Decimal
+
+	MonthFeeFiat decimal.Decimal
+
+	MonthRebalanceFee lnwire.MilliSatoshi
+}
+
+// ChannelRow contains the computed columns of a channel in list channels
+type ChannelRow struct {
+	lndclient.ChannelInfo
+	Alias      string
+	HTLC       ChanHTLC
+	Ratio      float64
+	Efficiency float64
+	MonthFee   decimal.Decimal
+
+	// MonthRebalanceFee is what the rebalances which refilled the channel
+	// during the last month cost.
+	MonthRebalanceFee lnwire.MilliSatoshi
+
+	// Trend is the sparkline of the ratio over the recorded snapshots.
+	Trend string
+}
+
+// monthNetFee returns the fees earned during the last month minus the
+// rebalance fees spent, in millisatoshis.
+func (r *ChannelRow) monthNetFee() int64 {
+	return int64(r.HTLC.Month.FeeMsat) - int64(r.MonthRebalanceFee)
 }
 
 func getStatus(ctx *cli.Context) error {
-	ctxb := context.Background()
+	ctxb := ctx.Context
 	client, err := getClient(ctxb, ctx)
 	if err != nil {
 		return fmt.Errorf("failed to connect to LND: %w", err)
@@ -79,7 +110,7 @@ func getStatus(ctx *cli.Context) error {
 
 	resp, err := client.Client.GetInfo(ctxb)
 	if err != nil {
-		return fmt.Errorf("client.GetInfo failed: %w", err)
+		return err
 	}
 
 	// IdentityPubkey is printed as a list of numbers. Fix this.
@@ -143,46 +174,66 @@ func getStatus(ctx *cli.Context) error {
 }
 
 func getBalance(ctx *cli.Context) error {
-	ctxb := context.Background()
+	ctxb := ctx.Context
 	client, err := getClient(ctxb, ctx)
 	if err != nil {
 		return fmt.Errorf("failed to connect to LND: %w", err)
 	}
 	defer client.Close()
 
+	unit, err := getAmountUnit(ctx)
+	if err != nil {
+		return err
+	}
+
 	var opts []lndclient.ListChannelsOption
-	if ctx.Bool("inactive_only") {
+	if ctx.Bool("inactive") {
 		opts = append(opts, func(r *lnrpc.ListChannelsRequest) {
 			r.InactiveOnly = true
 		})
 	}
-	if ctx.Bool("private_only") {
+	if ctx.Bool("private") {
 		opts = append(opts, func(r *lnrpc.ListChannelsRequest) {
 			r.PrivateOnly = true
 		})
 	}
 
 	resp, err := client.Client.ListChannels(
-		ctxb, ctx.Bool("active_only"), ctx.Bool("public_only"), opts...,
+		ctxb, ctx.Bool("active"), ctx.Bool("public"), opts...,
 	)
 	if err != nil {
-		return fmt.Errorf("client.ListChannels failed: %w", err)
+		return err
 	}
 
-	printBalance(resp)
+	printBalance(resp, unit)
 
 	// printRespJSON(resp)
 	return nil
 }
 
 func listChannels(ctx *cli.Context) error {
-	ctxb := context.Background()
+	ctxb := ctx.Context
 	client, err := getClient(ctxb, ctx)
 	if err != nil {
 		return fmt.Errorf("failed to connect to LND: %w", err)
 	}
 	defer client.Close()
 
+	view, err := getChannelView(ctx)
+	if err != nil {
+		return err
+	}
+
+	where, err := parseFilter(ctx.String("where"), channelFields)
+	if err != nil {
+		return fmt.Errorf("invalid --where: %w", err)
+	}
+
+	fiat, err := newFiatValuer(ctx)
+	if err != nil {
+		return err
+	}
+
 	var opts []lndclient.ListChannelsOption
 
 	// If the user requested channels with a particular key,
@@ -211,93 +262,158 @@ func listChannels(ctx *cli.Context) error {
 		})
 	}
 
-	resp, err := client.Client.ListChannels(
-		ctxb, ctx.Bool("active_only"), ctx.Bool("public_only"), opts...,
+	// The channels and the forwarding history don't depend on each other,
+	// so both are fetched at the same time.
+	var (
+		resp  []lndclient.ChannelInfo
+		count SumHTLC
 	)
-	if err != nil {
-		return fmt.Errorf("client.ListChannels failed: %w", err)
+	g, gctx := errgroup.WithContext(ctxb)
+	g.Go(func() error {
+		var err error
+		resp, err = client.Client.ListChannels(
+			gctx, ctx.Bool("active_only"), ctx.Bool("public_only"),
+			opts...,
+		)
+		return err
+	})
+	g.Go(func() error {
+		var err error
+		count, err = countHTLC(gctx, ctx, client, fiat)
+		return err
+	})
+
+	// Scanning the payment history may take a while, so it is only done
+	// if the rebalance fees are shown or filtered on, and only back to
+	// about the start of the month.
+	var rebalanceFees map[uint64]lnwire.MilliSatoshi
+	if view.needsRebalances() || where.uses(
+		"month_rebalance_fee_sat", "month_net_fee_sat",
+	) {
+
+		g.Go(func() error {
+			now := client.Now()
+
+			var err error
+			rebalanceFees, err = fetchRebalanceFees(
+				gctx, client.Client, now.Add(-time.Hour*24*30),
+				now,
+			)
+			return err
+		})
 	}
 
-	count, err := countHTLC(ctxb, ctx, client)
-	if err != nil {
+	var snapshots []channelSnapshot
+	if view.needsSnapshots() {
+		g.Go(func() error {
+			var err error
+			snapshots, err = loadSnapshots(gctx, ctx, client.Client)
+			return err
+		})
+	}
+	if err := g.Wait(); err != nil {
 		return err
 	}
 
-	printChannels(resp, count)
-	// printRespJSON(resp)
+	// The aliases are looked up for the peer column with --alias, and
+	// whenever the alias column is shown, sorted or filtered on.
+	var aliases map[route.Vertex]string
+	if ctx.Bool("alias") || view.needsAliases() || where.uses("alias") {
+		peers := make([]route.Vertex, 0, len(resp))
+		for _, c := range resp {
+			peers = append(peers, c.PubKeyBytes)
+		}
 
-	return nil
+		aliases, err = lookupAliases(ctxb, client.Client, peers)
+		if err != nil {
+			return err
+		}
+	}
+
+	rows := newChannelRows(resp, count, rebalanceFees, aliases)
+
+	trends := channelTrends(snapshots, client.Now())
+	for i := range rows {
+		rows[i].Trend = trends[rows[i].ChannelID]
+	}
+
+	var matching []ChannelRow
+	for _, r := range rows {
+		if where.match(r.field) {
+			matching = append(matching, r)
+		}
+	}
+
+	return view.print(view.apply(matching))
 }
 
 func listContracts(ctx *cli.Context) error {
-	ctxb := context.Background()
+	ctxb := ctx.Context
 	client, err := getClient(ctxb, ctx)
 	if err != nil {
 		return fmt.Errorf("failed to connect to LND: %w", err)
 	}
 	defer client.Close()
 
+	chanFmt, err := getChanFormat(ctx)
+	if err != nil {
+		return err
+	}
+
+	unit, err := getAmountUnit(ctx)
+	if err != nil {
+		return err
+	}
+	format := &rowFormat{chanFmt: chanFmt, unit: unit}
+
+	fiat, err := newFiatValuer(ctx)
+	if err != nil {
+		return err
+	}
+
 	var (
 		startTime, endTime     time.Time
 		indexOffset, maxEvents uint32
 	)
 	args := ctx.Args().Slice()
 
-	chanID := ctx.String("channel")
-
-	// If the user requested contracts with a particular channel id,
-	// parse it
-	var id uint64
-
-	if chanID != "" {
-		p := strings.Split(chanID, ":")
-
-		if len(p) == 3 {
-			p1, err1 := strconv.ParseUint(p[0], 10, 32)
-			p2, err2 := strconv.ParseUint(p[1], 10, 32)
-			p3, err3 := strconv.ParseUint(p[2], 10, 16)
-
-			if err1 != nil || err2 != nil || err3 != nil {
-				return fmt.Errorf("invalid --channel id: %s, should be in bbbbbb:iiii:p format", chanID)
-			}
-
-			c := lnwire.ShortChannelID{
-				BlockHeight: uint32(p1),
-				TxIndex:     uint32(p2),
-				TxPosition:  uint16(p3),
-			}
-			id = c.ToUint64()
-			// fmt.Printf("ID: %s\n", lnwire.NewShortChanIDFromInt(id))
-
-		} else {
-			return fmt.Errorf("invalid --channel id: %s, should be in bbbbbb:iiii:p format", chanID)
-		}
+	loc, err := time.LoadLocation(ctx.String("tz"))
+	if err != nil {
+		return fmt.Errorf("invalid --tz: %w", err)
 	}
+	now := client.Now()
 
 	switch {
+	case ctx.IsSet("since"):
+		startTime, err = parseTimeSpec(ctx.String("since"), now, loc)
+		if err != nil {
+			return fmt.Errorf("invalid --since: %w", err)
+		}
 	case ctx.IsSet("start_time"):
 		startTime = time.Unix(int64(ctx.Uint64("start_time")), 0)
 	case len(args) > 0:
-		startTimeUint, err := strconv.ParseUint(args[0], 10, 64)
+		startTime, err = parseTimeSpec(args[0], now, loc)
 		if err != nil {
 			return fmt.Errorf("unable to decode start_time %v", err)
 		}
-		startTime = time.Unix(int64(startTimeUint), 0)
 		args = args[1:]
 	default:
-		now := time.Now().UTC()
 		startTime = now.Add(-time.Hour * 24 * 30)
 	}
 
 	switch {
+	case ctx.IsSet("until"):
+		endTime, err = parseTimeSpec(ctx.String("until"), now, loc)
+		if err != nil {
+			return fmt.Errorf("invalid --until: %w", err)
+		}
 	case ctx.IsSet("end_time"):
 		endTime = time.Unix(int64(ctx.Uint64("end_time")), 0)
 	case len(args) > 0:
-		endTimeUint, err := strconv.ParseUint(args[0], 10, 64)
+		endTime, err = parseTimeSpec(args[0], now, loc)
 		if err != nil {
 			return fmt.Errorf("unable to decode end_time: %v", err)
 		}
-		endTime = time.Unix(int64(endTimeUint), 0)
 		args = args[1:]
 	}
 
@@ -324,60 +440,75 @@ func listContracts(ctx *cli.Context) error {
 		maxEvents = uint32(m)
 	}
 
+	filter, err := newContractFilter(ctxb, ctx, client)
+	if err != nil {
+		return err
+	}
+
+	if maxEvents == 0 {
+		maxEvents = defaultMaxEvents
+	}
+
 	req := lndclient.ForwardingHistoryRequest{
 		StartTime: startTime,
 		EndTime:   endTime,
 		Offset:    indexOffset,
 		MaxEvents: maxEvents,
 	}
-	resp, err := client.Client.ForwardingHistory(ctxb, req)
+	events, err := fetchContracts(ctxb, client.Client, req, filter)
 	if err != nil {
 		return err
 	}
 
-	printContracts(resp.Events, id)
-	//printRespJSON(resp)
-
-	return nil
+	return printContracts(ctxb, events, loc, format, fiat)
 }
 
+// countHTLC sums up the forwards of the last day, week and month per
+// channel. If fiat is set, the fees are valued at the price of the day they
+// were earned.
 func countHTLC(callerCtx context.Context, ctx *cli.Context,
-	client *lndclient.GrpcLndServices) (SumHTLC, error) {
+	client *lndServices, fiat *fiatValuer) (SumHTLC, error) {
 
 	sum := make(SumHTLC)
 
-	now := time.Now().UTC()
+	now := client.Now().UTC()
 
 	startDay := now.Add(-time.Hour * 24)
 	startWeek := now.Add(-time.Hour * 24 * 7)
 	startMonth := now.Add(-time.Hour * 24 * 30)
 
-	req := lndclient.ForwardingHistoryRequest{
-		StartTime: startMonth,
-		EndTime:   now,
-		Offset:    0,
-		MaxEvents: 50000,
-	}
-	resp, err := client.Client.ForwardingHistory(callerCtx, req)
+	events, err := fetchForwards(callerCtx, client.Client, startMonth, now)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, event := range resp.Events {
+	for _, event := range events {
 		t := event.Timestamp
+
+		var feeFiat decimal.Decimal
+		if fiat != nil {
+			feeFiat, err = fiat.value(callerCtx, event.FeeMsat, t)
+			if err != nil {
+				return nil, err
+			}
+		}
+
 		if event.ChannelIn > 0 {
 			m := sum[event.ChannelIn]
 			if t.After(startDay) {
 				m.Day.AmountSatIn += event.AmountMsatIn.ToSatoshis()
 				m.Day.FeeMsat += event.FeeMsat
+				m.Day.FeeFiat = m.Day.FeeFiat.Add(feeFiat)
 			}
 			if t.After(startWeek) {
 				m.Week.AmountSatIn += event.AmountMsatIn.ToSatoshis()
 				m.Week.FeeMsat += event.FeeMsat
+				m.Week.FeeFiat = m.Week.FeeFiat.Add(feeFiat)
 			}
 			if t.After(startMonth) {
 				m.Month.AmountSatIn += event.AmountMsatIn.ToSatoshis()
 				m.Month.FeeMsat += event.FeeMsat
+				m.Month.FeeFiat = m.Month.FeeFiat.Add(feeFiat)
 			}
 			sum[event.ChannelIn] = m
 		}
@@ -386,14 +517,17 @@ func countHTLC(callerCtx context.Context, ctx *cli.Context,
 			if t.After(startDay) {
 				m.Day.AmountSatOut += event.AmountMsatOut.ToSatoshis()
 				m.Day.FeeMsat += event.FeeMsat
+				m.Day.FeeFiat = m.Day.FeeFiat.Add(feeFiat)
 			}
 			if t.After(startWeek) {
 				m.Week.AmountSatOut += event.AmountMsatOut.ToSatoshis()
 				m.Week.FeeMsat += event.FeeMsat
+				m.Week.FeeFiat = m.Week.FeeFiat.Add(feeFiat)
 			}
 			if t.After(startMonth) {
 				m.Month.AmountSatOut += event.AmountMsatOut.ToSatoshis()
 				m.Month.FeeMsat += event.FeeMsat
+				m.Month.FeeFiat = m.Month.FeeFiat.Add(feeFiat)
 			}
 			sum[event.ChannelOut] = m
 		}
@@ -401,17 +535,19 @@ func countHTLC(callerCtx context.Context, ctx *cli.Context,
 	return sum, nil
 }
 
-func printBalance(channels []lndclient.ChannelInfo) {
-	title := " Capacity " +
-		"|    Local " +
-		"|   Remote " +
-		"| CommitFee" +
+func printBalance(channels []lndclient.ChannelInfo, unit amountUnit) {
+	w := unit.width()
+
+	title := fmt.Sprintf("%*s ", w, "Capacity") +
+		fmt.Sprintf("|%*s ", w, "Local") +
+		fmt.Sprintf("|%*s ", w, "Remote") +
+		fmt.Sprintf("|%*s ", w, "CommitFee") +
 		"| Ratio " +
-		"| Total In Out Amount" +
+		fmt.Sprintf("|%*s ", 2*w+1, "Total In Out Amount") +
 		"| Efficiency"
 	line := strings.Repeat("-", len(title))
 
-	const row = "%9d |%9d |%9d |%9d | %5d%% |%9d %-9d |%5d%%\n"
+	const row = "%*s |%*s |%*s |%*s | %5d%% |%*s %-*s |%5d%%\n"
 
 	fmt.Println(title)
 	fmt.Println(line)
@@ -433,167 +569,177 @@ func printBalance(channels []lndclient.ChannelInfo) {
 	}
 
 	fmt.Printf(row,
-		b.Capacity,
-		b.LocalBalance,
-		b.RemoteBalance,
-		b.CommitFee,
+		w, unit.format(sat(b.Capacity)),
+		w, unit.format(sat(b.LocalBalance)),
+		w, unit.format(sat(b.RemoteBalance)),
+		w, unit.format(sat(b.CommitFee)),
 		int64(math.Round(b.Ratio)),
-		b.AmountIn,
-		b.AmountOut,
+		w, unit.format(sat(b.AmountIn)),
+		w, unit.format(sat(b.AmountOut)),
 		int64(math.Round(b.Efficiency)),
 	)
 }
 
-func printChannels(channels []lndclient.ChannelInfo, sum SumHTLC) {
+// newChannelRows computes the columns of list channels for every channel,
+// sorted by channel id, newest first.
+func newChannelRows(channels []lndclient.ChannelInfo, sum SumHTLC,
+	rebalanceFees map[uint64]lnwire.MilliSatoshi,
+	aliases map[route.Vertex]string) []ChannelRow {
 
-	t := TotalChannels{}
+	rows := make([]ChannelRow, 0, len(channels))
+	for _, c := range channels {
+		r := ChannelRow{
+			ChannelInfo: c,
+			Alias:       aliases[c.PubKeyBytes],
+			HTLC:        sum[c.ChannelID],
 
-	// Table formaters
-	title := "  Num |    Channel ID | Public Key" +
-		"| Capacity |    Local |   Remote | Ratio " +
-		"|   Day In Out Amount" +
-		"| Month In Out Amount" +
-		"|Mon Fee" +
-		"| Total In Out Amount" +
-		"| Effcy"
-	line := strings.Repeat("-", len(title))
-	const row = "%5d%s|%11s |%10s |%9d |%9d |%9d |%5d%% |%9d %-9d |%9d %-9d |%6s |%9d %-9d |%5d%%\n"
+			MonthRebalanceFee: rebalanceFees[c.ChannelID],
+		}
 
-	// Print table
-	fmt.Println(title)
-	fmt.Println(line)
+		if c.LocalBalance > 0 {
+			r.Ratio = float64(c.LocalBalance) / float64(c.LocalBalance+c.RemoteBalance) * 100
+			r.Efficiency = (float64(c.TotalReceived) + float64(c.TotalSent)) / float64(c.Capacity) * 100
+		}
+
+		r.MonthFee = decimal.NewFromInt(int64(r.HTLC.Month.FeeMsat)).Div(decimal.NewFromInt(1000))
+
+		rows = append(rows, r)
+	}
 
-	sort.SliceStable(channels, func(i, j int) bool {
-		return channels[i].ChannelID > channels[j].ChannelID
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i].ChannelID > rows[j].ChannelID
 	})
 
-	for i, c := range channels {
-		var ratio float64
-		var efficiency float64
+	return rows
+}
 
-		totalIn := c.TotalReceived
-		totalOut := c.TotalSent
+// printChannels prints the rows as a table of the given columns followed by
+// a row of totals. Inactive channels are marked with a - after their number.
+func printChannels(rows []ChannelRow, columns []*channelColumn,
+	f *rowFormat) {
 
-		if c.LocalBalance > 0 {
-			ratio = float64(c.LocalBalance) / float64(c.LocalBalance+c.RemoteBalance) * 100
-			efficiency = (float64(totalIn) + float64(totalOut)) / float64(c.Capacity) * 100
+	headers := []string{"Num"}
+	for _, c := range columns {
+		headers = append(headers, c.header)
+	}
+
+	table := newTextTable(headers...)
+	for j, c := range columns {
+		if c.left {
+			table.alignLeft(j + 1)
 		}
+	}
 
+	for i := range rows {
 		var active string = "-"
-		if c.Active {
+		if rows[i].Active {
 			active = " "
 		}
 
-		m := lnwire.NewShortChanIDFromInt(c.ChannelID)
-		mark := fmt.Sprintf("%7d:%04d:%1d", m.BlockHeight, m.TxIndex, m.TxPosition)
-
-		var monthFee string
-		monthFeeSat := decimal.NewFromInt(int64(sum[c.ChannelID].Month.FeeMsat)).Div(decimal.NewFromInt(1000))
+		cells := []string{fmt.Sprintf("%d%s", i+1, active)}
+		for _, c := range columns {
+			if c.text != nil {
+				cells = append(cells, c.text(&rows[i], f))
+			} else {
+				cells = append(cells, fmt.Sprint(c.value(&rows[i], f)))
+			}
+		}
+		table.addRow(cells...)
+	}
 
-		switch {
-		case monthFeeSat.IsZero():
-			monthFee = "0"
-		case monthFeeSat.LessThan(decimal.NewFromInt(100)):
-			monthFee = monthFeeSat.StringFixed(3)
-		default:
-			monthFee = monthFeeSat.StringFixedBank(0)
+	t := newChannelTotals(rows)
 
+	totals := []string{fmt.Sprintf("%d ", len(rows))}
+	for _, c := range columns {
+		var total string
+		if c.total != nil {
+			total = c.total(&t, f)
 		}
+		totals = append(totals, total)
+	}
+	table.setFooter(totals...)
 
-		fmt.Printf(row,
-			i+1,
-			active,
-			mark,
-			hex.EncodeToString(c.PubKeyBytes[:4]),
-			c.Capacity,
-			c.LocalBalance,
-			c.RemoteBalance,
-			int64(math.Round(ratio)),
-			sum[c.ChannelID].Day.AmountSatIn,
-			sum[c.ChannelID].Day.AmountSatOut,
-			sum[c.ChannelID].Month.AmountSatIn,
-			sum[c.ChannelID].Month.AmountSatOut,
-			monthFee,
-			totalIn,
-			totalOut,
-			int64(math.Round(efficiency)),
-		)
+	table.print()
+}
+
+// newChannelTotals sums up the rows.
+func newChannelTotals(rows []ChannelRow) TotalChannels {
+	t := TotalChannels{}
 
+	for _, c := range rows {
 		t.Capacity += c.Capacity
 		t.LocalBalance += c.LocalBalance
 		t.RemoteBalance += c.RemoteBalance
-		t.AmountIn += totalIn
-		t.AmountOut += totalOut
+		t.AmountIn += c.TotalReceived
+		t.AmountOut += c.TotalSent
 		t.CommitFee += c.CommitFee
-		t.MonthFee = t.MonthFee.Add(monthFeeSat)
-
-		t.DayAmountSatIn += sum[c.ChannelID].Day.AmountSatIn
-		t.DayAmountSatOut += sum[c.ChannelID].Day.AmountSatOut
-		t.MonthAmountSatIn += sum[c.ChannelID].Month.AmountSatIn
-		t.MonthAmountSatOut += sum[c.ChannelID].Month.AmountSatOut
+		t.MonthFee = t.MonthFee.Add(c.MonthFee)
+		t.MonthFeeFiat = t.MonthFeeFiat.Add(c.HTLC.Month.FeeFiat)
+		t.MonthRebalanceFee += c.MonthRebalanceFee
 
+		t.DayAmountSatIn += c.HTLC.Day.AmountSatIn
+		t.DayAmountSatOut += c.HTLC.Day.AmountSatOut
+		t.MonthAmountSatIn += c.HTLC.Month.AmountSatIn
+		t.MonthAmountSatOut += c.HTLC.Month.AmountSatOut
 	}
 	if t.LocalBalance > 0 {
 		t.Ratio = float64(t.LocalBalance) / float64(t.LocalBalance+t.RemoteBalance) * 100
 		t.Efficiency = (float64(t.AmountIn) + float64(t.AmountOut)) / float64(t.Capacity) * 100
 	}
 
-	// Print total row
-	fmt.Println(line)
-	fmt.Printf(row,
-		len(channels),
-		" ",
-		"              ",
-		"  ",
-		t.Capacity,
-		t.LocalBalance,
-		t.RemoteBalance,
-		int64(math.Round(t.Ratio)),
-		t.DayAmountSatIn,
-		t.DayAmountSatOut,
-		t.MonthAmountSatIn,
-		t.MonthAmountSatOut,
-		t.MonthFee.StringFixedBank(0),
-		t.AmountIn,
-		t.AmountOut,
-		int64(math.Round(t.Efficiency)),
-	)
-	return
+	return t
 }
 
-func printContracts(contracts []lndclient.ForwardingEvent, id uint64) {
+// printContracts prints the contracts, newest first. If fiat is set, the
+// fee of each contract is also valued at the price of its day.
+func printContracts(callerCtx context.Context,
+	contracts []lndclient.ForwardingEvent, loc *time.Location,
+	f *rowFormat, fiat *fiatValuer) error {
+
+	sort.SliceStable(contracts, func(i, j int) bool {
+		return contracts[i].Timestamp.After(contracts[j].Timestamp)
+	})
+
+	// Value all fees first, so a missing price doesn't cut the table
+	// short.
+	var fees []decimal.Decimal
+	if fiat != nil {
+		fees = make([]decimal.Decimal, len(contracts))
+		for i, c := range contracts {
+			var err error
+			fees[i], err = fiat.value(callerCtx, c.FeeMsat, c.Timestamp)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	w := f.unit.width()
+
 	// Table formater
 	title := "  Num " +
 		"|            Time           " +
 		"|  Timestamp " +
 		"|  Channel In   " +
 		"|  Channel Out  " +
-		"| Amount In" +
-		"|Amount Out" +
-		"| Fee Msat"
+		fmt.Sprintf("|%*s ", w, "Amount In") +
+		fmt.Sprintf("|%*s ", w, "Amount Out") +
+		fmt.Sprintf("|%*s", w, "Fee")
+	if fiat != nil {
+		title += fmt.Sprintf(" |%9s", "Fee "+fiat.currency)
+	}
 
 	line := strings.Repeat("-", len(title))
-	const row = "%5d | %24s | %10d |%10s |%10s |%9d |%9d |%6d\n"
+	const row = "%5d | %25s | %10d |%10s |%10s |%*s |%*s |%*s"
 
 	fmt.Println(title)
 	fmt.Println(line)
 
-	sort.SliceStable(contracts, func(i, j int) bool {
-		return contracts[i].Timestamp.After(contracts[j].Timestamp)
-	})
-
 	for i, c := range contracts {
-		if id != 0 && id != c.ChannelIn && id != c.ChannelOut {
-			continue
-		}
+		tm := c.Timestamp.In(loc).Format(time.RFC3339)
 
-		tm := c.Timestamp.Format(time.RFC3339)
-
-		in := lnwire.NewShortChanIDFromInt(c.ChannelIn)
-		out := lnwire.NewShortChanIDFromInt(c.ChannelOut)
-
-		markIn := fmt.Sprintf("%7d:%04d:%1d", in.BlockHeight, in.TxIndex, in.TxPosition)
-		markOut := fmt.Sprintf("%7d:%04d:%1d", out.BlockHeight, out.TxIndex, out.TxPosition)
+		markIn := f.chanFmt.format(c.ChannelIn)
+		markOut := f.chanFmt.format(c.ChannelOut)
 
 		fmt.Printf(row,
 			i+1,
@@ -601,12 +747,27 @@ func printContracts(contracts []lndclient.ForwardingEvent, id uint64) {
 			c.Timestamp.Unix(),
 			markIn,
 			markOut,
-			c.AmountMsatIn.ToSatoshis(),
-			c.AmountMsatOut.ToSatoshis(),
-			c.FeeMsat,
+			w, f.unit.format(c.AmountMsatIn),
+			w, f.unit.format(c.AmountMsatOut),
+			w, f.unit.format(c.FeeMsat),
 		)
+
+		if fiat != nil {
+			fmt.Printf(" |%9s", formatFiat(fees[i]))
+		}
+		fmt.Println()
 	}
-	return
+	return nil
+}
+
+// truncate shortens s to at most n runes.
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+
+	return string(r[:n])
 }
 
 func printRespJSON(resp interface{}) {
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	defaultLnbDir         = "~/.lnb"
+	defaultConfigFilename = "lnb.conf"
+)
+
+// loadConfig reads the lnb config file and applies its options to the global
+// flags which were not set on the command line. The file uses the same
+// key=value layout as lnd.conf, where every key is the name of a global flag:
+//
+//	[Application Options]
+//	rpcserver=abcdef.onion:10009
+//	proxy=socks5://127.0.0.1:9050
+//
+// A missing config file is only an error if its path was given explicitly.
+func loadConfig(ctx *cli.Context) error {
+	path := cleanAndExpandPath(ctx.String("configfile"))
+
+	file, err := os.Open(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist) && !ctx.IsSet("configfile"):
+		return nil
+
+	case err != nil:
+		return fmt.Errorf("unable to open config file: %w", err)
+	}
+	defer file.Close()
+
+	known := make(map[string]bool)
+	for _, flag := range ctx.App.Flags {
+		for _, name := range flag.Names() {
+			known[name] = true
+		}
+	}
+
+	scanner := bufio.NewScanner(file)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+
+		// Skip blank lines, comments and section headers. Sections are
+		// allowed only to keep the file familiar to lnd users.
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "#"), strings.HasPrefix(line, ";"):
+			continue
+		case strings.HasPrefix(line, "["):
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return fmt.Errorf("%s:%d: expected key=value, got %q",
+				path, lineNum, line)
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+
+		if !known[key] || key == "configfile" {
+			return fmt.Errorf("%s:%d: unknown option %q", path,
+				lineNum, key)
+		}
+
+		// Options given on the command line always win.
+		if ctx.IsSet(key) {
+			continue
+		}
+
+		if err := ctx.Set(key, value); err != nil {
+			return fmt.Errorf("%s:%d: invalid value for %s: %w",
+				path, lineNum, key, err)
+		}
+	}
+
+	return scanner.Err()
+}
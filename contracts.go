@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/routing/route"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/sync/errgroup"
+)
+
+// contractsPageSize is the number of events fetched per call while looking
+// for contracts which match a filter.
+const contractsPageSize = 10000
+
+// contractFilter selects the forwarding events of a set of channels,
+// optionally in one direction only.
+type contractFilter struct {
+	// channels is empty if all contracts are wanted.
+	channels map[uint64]bool
+
+	// in and out restrict the match to contracts which entered or left
+	// through one of the channels. If neither or both are set, either
+	// direction matches.
+	in  bool
+	out bool
+
+	// where is the --where expression the contracts must satisfy.
+	where *filterExpr
+}
+
+// active returns true if the filter drops any contracts at all.
+func (f *contractFilter) active() bool {
+	return len(f.channels) > 0 || f.where.root != nil
+}
+
+// match returns true if the contract passes the filter.
+func (f *contractFilter) match(e lndclient.ForwardingEvent) bool {
+	if !f.where.match(contractRecord(e)) {
+		return false
+	}
+
+	if len(f.channels) == 0 {
+		return true
+	}
+
+	in, out := f.channels[e.ChannelIn], f.channels[e.ChannelOut]
+	switch {
+	case f.in && !f.out:
+		return in
+	case f.out && !f.in:
+		return out
+	default:
+		return in || out
+	}
+}
+
+// newContractFilter builds the filter from the --channel, --peer, --in,
+// --out and --where flags. Peers are resolved to the open and closed
+// channels we have had with them, since the history has contracts of both.
+func newContractFilter(callerCtx context.Context, ctx *cli.Context,
+	client *lndServices) (*contractFilter, error) {
+
+	where, err := parseFilter(ctx.String("where"), contractFields)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --where: %w", err)
+	}
+
+	f := &contractFilter{
+		channels: make(map[uint64]bool),
+		in:       ctx.Bool("in"),
+		out:      ctx.Bool("out"),
+		where:    where,
+	}
+
+	ids, err := resolveChanIDs(
+		callerCtx, client.Client, ctx.StringSlice("channel"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		f.channels[id] = true
+	}
+
+	if peers := ctx.StringSlice("peer"); len(peers) > 0 {
+		var (
+			channels []lndclient.ChannelInfo
+			closed   []lndclient.ClosedChannel
+		)
+		g, gctx := errgroup.WithContext(callerCtx)
+		g.Go(func() error {
+			var err error
+			channels, err = client.Client.ListChannels(
+				gctx, false, false,
+			)
+			return err
+		})
+		g.Go(func() error {
+			var err error
+			closed, err = client.Client.ClosedChannels(gctx)
+			return err
+		})
+		if err := g.Wait(); err != nil {
+			return nil, err
+		}
+
+		for _, peer := range peers {
+			pk, err := route.NewVertexFromStr(peer)
+			if err != nil {
+				return nil, fmt.Errorf("invalid --peer pubkey: %v",
+					err)
+			}
+
+			var found bool
+			for _, c := range channels {
+				if c.PubKeyBytes == pk {
+					f.channels[c.ChannelID] = true
+					found = true
+				}
+			}
+			for _, c := range closed {
+				if c.PubKeyBytes == pk {
+					f.channels[c.ChannelID] = true
+					found = true
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("no channels with peer %s",
+					peer)
+			}
+		}
+	}
+
+	if (f.in || f.out) && len(f.channels) == 0 {
+		return nil, fmt.Errorf("--in and --out need --channel or --peer")
+	}
+
+	return f, nil
+}
+
+// fetchContracts pages through the forwarding history from the offset of
+// req on until req.MaxEvents contracts passing the filter are collected or
+// the history is exhausted. Filtering happens while paging, so the limit
+// applies to the matching contracts rather than to all of them.
+func fetchContracts(ctx context.Context, client lndAPI,
+	req lndclient.ForwardingHistoryRequest, filter *contractFilter) (
+	[]lndclient.ForwardingEvent, error) {
+
+	maxEvents := int(req.MaxEvents)
+	if filter.active() {
+		req.MaxEvents = contractsPageSize
+	}
+
+	var events []lndclient.ForwardingEvent
+	for {
+		resp, err := client.ForwardingHistory(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, e := range resp.Events {
+			if !filter.match(e) {
+				continue
+			}
+
+			events = append(events, e)
+			if len(events) == maxEvents {
+				return events, nil
+			}
+		}
+
+		if len(resp.Events) < int(req.MaxEvents) {
+			return events, nil
+		}
+		req.Offset = resp.LastIndexOffset
+	}
+}
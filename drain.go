@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcutil"
+)
+
+const (
+	// drainWindowDays is the number of days of forwards the flow rate of
+	// a drain forecast is taken from, the week list channels sums up.
+	drainWindowDays = 7
+
+	// defaultDrainDays is the forecast below which a channel is flagged
+	// unless --drain-days says otherwise.
+	defaultDrainDays = 7
+)
+
+// spendable returns what is left of a balance above the reserve.
+func spendable(balance, reserve btcutil.Amount) btcutil.Amount {
+	return max(balance-reserve, 0)
+}
+
+// netOutflow returns the amount per day forwarded out of the channel minus
+// the amount forwarded into it over the last week.
+func (r *ChannelRow) netOutflow() float64 {
+	net := r.HTLC.Week.AmountSatOut - r.HTLC.Week.AmountSatIn
+	return float64(net) / drainWindowDays
+}
+
+// etaEmpty returns the number of days until the spendable local balance
+// runs out at the current flow, false if the channel isn't draining.
+func (r *ChannelRow) etaEmpty() (float64, bool) {
+	rate := r.netOutflow()
+	if rate <= 0 {
+		return 0, false
+	}
+
+	var reserve btcutil.Amount
+	if r.LocalConstraints != nil {
+		reserve = r.LocalConstraints.Reserve
+	}
+
+	return float64(spendable(r.LocalBalance, reserve)) / rate, true
+}
+
+// etaFull returns the number of days until the spendable remote balance runs
+// out at the current flow, false if the channel isn't filling up.
+func (r *ChannelRow) etaFull() (float64, bool) {
+	rate := -r.netOutflow()
+	if rate <= 0 {
+		return 0, false
+	}
+
+	var reserve btcutil.Amount
+	if r.RemoteConstraints != nil {
+		reserve = r.RemoteConstraints.Reserve
+	}
+
+	return float64(spendable(r.RemoteBalance, reserve)) / rate, true
+}
+
+// etaColumn returns a column of a drain forecast in days. Channels without
+// a forecast have no value, those which reach the end within --drain-days
+// are flagged with a !.
+func etaColumn(name, header string,
+	eta func(r *ChannelRow) (float64, bool)) channelColumn {
+
+	return channelColumn{
+		name:   name,
+		header: header,
+		value: func(r *ChannelRow, _ *rowFormat) interface{} {
+			days, ok := eta(r)
+			if !ok {
+				return nil
+			}
+
+			return days
+		},
+		text: func(r *ChannelRow, f *rowFormat) string {
+			days, ok := eta(r)
+			if !ok {
+				return "-"
+			}
+
+			text := fmt.Sprintf("%.0fd", days)
+			if days < 10 {
+				text = fmt.Sprintf("%.1fd", days)
+			}
+			if days <= float64(f.drainDays) {
+				text += "!"
+			}
+
+			return text
+		},
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/lightninglabs/lndclient"
+)
+
+// drainRow returns a channel row with the balances, reserves and the week
+// of forwards in and out of it.
+func drainRow(local, remote, localReserve, remoteReserve, in,
+	out btcutil.Amount) *ChannelRow {
+
+	r := &ChannelRow{
+		ChannelInfo: lndclient.ChannelInfo{
+			LocalBalance:  local,
+			RemoteBalance: remote,
+			LocalConstraints: &lndclient.ChannelConstraints{
+				Reserve: localReserve,
+			},
+			RemoteConstraints: &lndclient.ChannelConstraints{
+				Reserve: remoteReserve,
+			},
+		},
+	}
+	r.HTLC.Week.AmountSatIn = in
+	r.HTLC.Week.AmountSatOut = out
+
+	return r
+}
+
+// TestDrainForecast checks the days until a channel runs empty or full at
+// the flow of the last week, above the reserves.
+func TestDrainForecast(t *testing.T) {
+	// A channel without constraints has no reserve.
+	noConstraints := drainRow(35000, 0, 0, 0, 0, 7000)
+	noConstraints.LocalConstraints = nil
+	noConstraints.RemoteConstraints = nil
+
+	tests := []struct {
+		name        string
+		row         *ChannelRow
+		empty, full float64
+		hasEmpty    bool
+		hasFull     bool
+	}{
+		{
+			// 70000 sat a week out, 10000 a day.
+			name: "draining",
+			row: drainRow(
+				110000, 500000, 10000, 10000, 30000, 100000,
+			),
+			empty:    10,
+			hasEmpty: true,
+		},
+		{
+			// 14000 sat a week in, 2000 a day.
+			name: "filling up",
+			row: drainRow(
+				500000, 40000, 10000, 20000, 14000, 0,
+			),
+			full:    10,
+			hasFull: true,
+		},
+		{
+			name: "balanced flow",
+			row:  drainRow(500000, 500000, 0, 0, 70000, 70000),
+		},
+		{
+			name: "no forwards",
+			row:  drainRow(500000, 500000, 0, 0, 0, 0),
+		},
+		{
+			name:     "at the reserve",
+			row:      drainRow(10000, 500000, 10000, 0, 0, 7000),
+			hasEmpty: true,
+		},
+		{
+			name:     "below the reserve",
+			row:      drainRow(5000, 500000, 10000, 0, 0, 7000),
+			hasEmpty: true,
+		},
+		{
+			name:     "without constraints",
+			row:      noConstraints,
+			empty:    35,
+			hasEmpty: true,
+		},
+	}
+
+	for _, test := range tests {
+		empty, ok := test.row.etaEmpty()
+		if ok != test.hasEmpty || math.Abs(empty-test.empty) > 1e-9 {
+			t.Errorf("%s: empty in %v days (%v), want %v (%v)",
+				test.name, empty, ok, test.empty, test.hasEmpty)
+		}
+
+		full, ok := test.row.etaFull()
+		if ok != test.hasFull || math.Abs(full-test.full) > 1e-9 {
+			t.Errorf("%s: full in %v days (%v), want %v (%v)",
+				test.name, full, ok, test.full, test.hasFull)
+		}
+	}
+}
@@ -0,0 +1,554 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// exprType is the type of a value in a filter expression.
+type exprType int
+
+const (
+	exprNumber exprType = iota
+	exprBool
+	exprString
+)
+
+// String returns the name of the type as used in error messages.
+func (t exprType) String() string {
+	switch t {
+	case exprNumber:
+		return "number"
+	case exprBool:
+		return "boolean"
+	default:
+		return "string"
+	}
+}
+
+// exprValue is a value in a filter expression. Only the member matching
+// the static type of the expression is set.
+type exprValue struct {
+	num  float64
+	flag bool
+	str  string
+}
+
+// exprRecord returns the value of a field of the row being filtered.
+type exprRecord func(field string) exprValue
+
+// exprFields lists the fields a filter expression may refer to.
+type exprFields map[string]exprType
+
+// exprError is a syntax or type error in a filter expression. It points at
+// the offending token.
+type exprError struct {
+	src string
+	pos int
+	len int
+	msg string
+}
+
+// Error returns the message followed by the expression with the offending
+// token underlined.
+func (e *exprError) Error() string {
+	return fmt.Sprintf("%s\n  %s\n  %s%s", e.msg, e.src,
+		strings.Repeat(" ", e.pos), strings.Repeat("^", max(e.len, 1)))
+}
+
+// tokenKind is the kind of a lexical token of a filter expression.
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokOp
+	tokLParen
+	tokRParen
+)
+
+// token is a lexical token together with its position in the source.
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// exprOperators are the operators of the language, longest first so that
+// <= isn't read as <.
+var exprOperators = []string{"&&", "||", "<=", ">=", "==", "!=", "<", ">",
+	"!"}
+
+// tokenize splits the expression into tokens.
+func tokenize(src string) ([]token, error) {
+	var tokens []token
+
+	runes := []rune(src)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		start := i
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+			continue
+
+		case r == '(':
+			tokens = append(tokens, token{tokLParen, "(", start})
+			i++
+			continue
+
+		case r == ')':
+			tokens = append(tokens, token{tokRParen, ")", start})
+			i++
+			continue
+
+		case unicode.IsLetter(r) || r == '_':
+			for i < len(runes) && (unicode.IsLetter(runes[i]) ||
+				unicode.IsDigit(runes[i]) || runes[i] == '_') {
+
+				i++
+			}
+			tokens = append(tokens, token{
+				tokIdent, string(runes[start:i]), start,
+			})
+			continue
+
+		case unicode.IsDigit(r) || r == '.':
+			for i < len(runes) && (unicode.IsDigit(runes[i]) ||
+				runes[i] == '.') {
+
+				i++
+			}
+			tokens = append(tokens, token{
+				tokNumber, string(runes[start:i]), start,
+			})
+			continue
+
+		case r == '"' || r == '\'':
+			i++
+			for i < len(runes) && runes[i] != r {
+				i++
+			}
+			if i == len(runes) {
+				return nil, &exprError{
+					src: src, pos: start, len: i - start,
+					msg: "unterminated string",
+				}
+			}
+			i++
+			tokens = append(tokens, token{
+				tokString, string(runes[start+1 : i-1]), start,
+			})
+			continue
+		}
+
+		var op string
+		for _, o := range exprOperators {
+			if strings.HasPrefix(string(runes[i:]), o) {
+				op = o
+				break
+			}
+		}
+		if op == "" {
+			return nil, &exprError{
+				src: src, pos: start, len: 1,
+				msg: fmt.Sprintf("unexpected character %q", r),
+			}
+		}
+
+		tokens = append(tokens, token{tokOp, op, start})
+		i += len([]rune(op))
+	}
+
+	return append(tokens, token{tokEOF, "", len(runes)}), nil
+}
+
+// exprNode is a compiled part of an expression.
+type exprNode struct {
+	typ  exprType
+	eval func(rec exprRecord) exprValue
+}
+
+// exprParser is a recursive descent parser for the grammar
+//
+//	or      = and { "||" and }
+//	and     = unary { "&&" unary }
+//	unary   = "!" unary | compare
+//	compare = primary [ ( "<" | "<=" | ">" | ">=" | "==" | "!=" ) primary ]
+//	primary = number | string | "true" | "false" | field | "(" or ")"
+//
+// Types are checked while parsing, so errors are found before any data is
+// fetched.
+type exprParser struct {
+	src    string
+	tokens []token
+	pos    int
+	fields exprFields
+
+	// used collects the fields the expression refers to.
+	used map[string]bool
+}
+
+// errorf returns an error pointing at tok.
+func (p *exprParser) errorf(tok token, format string,
+	args ...interface{}) error {
+
+	n := len([]rune(tok.text))
+	if tok.kind == tokString {
+		n += 2
+	}
+
+	return &exprError{
+		src: p.src,
+		pos: tok.pos,
+		len: n,
+		msg: fmt.Sprintf(format, args...),
+	}
+}
+
+// peek returns the current token.
+func (p *exprParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+// next consumes and returns the current token.
+func (p *exprParser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+
+	return tok
+}
+
+// isOp returns true if the current token is one of the operators.
+func (p *exprParser) isOp(ops ...string) bool {
+	tok := p.peek()
+	if tok.kind != tokOp {
+		return false
+	}
+
+	for _, op := range ops {
+		if tok.text == op {
+			return true
+		}
+	}
+
+	return false
+}
+
+// expectBool returns an error pointing at tok unless n is a boolean.
+func (p *exprParser) expectBool(n *exprNode, tok token) error {
+	if n.typ != exprBool {
+		return p.errorf(tok, "expected a condition, got a %v", n.typ)
+	}
+
+	return nil
+}
+
+func (p *exprParser) parseOr() (*exprNode, error) {
+	start := p.peek()
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isOp("||") {
+		p.next()
+		if err := p.expectBool(left, start); err != nil {
+			return nil, err
+		}
+
+		start = p.peek()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectBool(right, start); err != nil {
+			return nil, err
+		}
+
+		l, r := left, right
+		left = &exprNode{
+			typ: exprBool,
+			eval: func(rec exprRecord) exprValue {
+				return exprValue{
+					flag: l.eval(rec).flag || r.eval(rec).flag,
+				}
+			},
+		}
+	}
+
+	return left, nil
+}
+
+func (p *exprParser) parseAnd() (*exprNode, error) {
+	start := p.peek()
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isOp("&&") {
+		p.next()
+		if err := p.expectBool(left, start); err != nil {
+			return nil, err
+		}
+
+		start = p.peek()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectBool(right, start); err != nil {
+			return nil, err
+		}
+
+		l, r := left, right
+		left = &exprNode{
+			typ: exprBool,
+			eval: func(rec exprRecord) exprValue {
+				return exprValue{
+					flag: l.eval(rec).flag && r.eval(rec).flag,
+				}
+			},
+		}
+	}
+
+	return left, nil
+}
+
+func (p *exprParser) parseUnary() (*exprNode, error) {
+	if !p.isOp("!") {
+		return p.parseCompare()
+	}
+
+	p.next()
+	start := p.peek()
+	operand, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectBool(operand, start); err != nil {
+		return nil, err
+	}
+
+	return &exprNode{
+		typ: exprBool,
+		eval: func(rec exprRecord) exprValue {
+			return exprValue{flag: !operand.eval(rec).flag}
+		},
+	}, nil
+}
+
+func (p *exprParser) parseCompare() (*exprNode, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.isOp("<", "<=", ">", ">=", "==", "!=") {
+		return left, nil
+	}
+	op := p.next()
+
+	rightTok := p.peek()
+	right, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	if left.typ != right.typ {
+		return nil, p.errorf(rightTok, "can't compare a %v with a %v",
+			left.typ, right.typ)
+	}
+	if left.typ != exprNumber && op.text != "==" && op.text != "!=" {
+		return nil, p.errorf(op, "operator %s needs numbers, got a %v",
+			op.text, left.typ)
+	}
+
+	var cmp func(l, r exprValue) bool
+	switch left.typ {
+	case exprNumber:
+		cmp = map[string]func(l, r exprValue) bool{
+			"<":  func(l, r exprValue) bool { return l.num < r.num },
+			"<=": func(l, r exprValue) bool { return l.num <= r.num },
+			">":  func(l, r exprValue) bool { return l.num > r.num },
+			">=": func(l, r exprValue) bool { return l.num >= r.num },
+			"==": func(l, r exprValue) bool { return l.num == r.num },
+			"!=": func(l, r exprValue) bool { return l.num != r.num },
+		}[op.text]
+
+	case exprBool:
+		cmp = func(l, r exprValue) bool { return l.flag == r.flag }
+
+	case exprString:
+		cmp = func(l, r exprValue) bool { return l.str == r.str }
+	}
+
+	eq := cmp
+	if left.typ != exprNumber && op.text == "!=" {
+		cmp = func(l, r exprValue) bool { return !eq(l, r) }
+	}
+
+	return &exprNode{
+		typ: exprBool,
+		eval: func(rec exprRecord) exprValue {
+			return exprValue{flag: cmp(left.eval(rec), right.eval(rec))}
+		},
+	}, nil
+}
+
+func (p *exprParser) parsePrimary() (*exprNode, error) {
+	tok := p.next()
+
+	switch tok.kind {
+	case tokNumber:
+		num, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, p.errorf(tok, "invalid number %q", tok.text)
+		}
+
+		return &exprNode{
+			typ: exprNumber,
+			eval: func(exprRecord) exprValue {
+				return exprValue{num: num}
+			},
+		}, nil
+
+	case tokString:
+		return &exprNode{
+			typ: exprString,
+			eval: func(exprRecord) exprValue {
+				return exprValue{str: tok.text}
+			},
+		}, nil
+
+	case tokIdent:
+		switch tok.text {
+		case "true", "false":
+			flag := tok.text == "true"
+			return &exprNode{
+				typ: exprBool,
+				eval: func(exprRecord) exprValue {
+					return exprValue{flag: flag}
+				},
+			}, nil
+		}
+
+		typ, ok := p.fields[tok.text]
+		if !ok {
+			return nil, p.errorf(tok, "unknown field %q, known "+
+				"fields are %s", tok.text, p.fieldNames())
+		}
+
+		field := tok.text
+		p.used[field] = true
+
+		return &exprNode{
+			typ: typ,
+			eval: func(rec exprRecord) exprValue {
+				return rec(field)
+			},
+		}, nil
+
+	case tokLParen:
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		if closing := p.next(); closing.kind != tokRParen {
+			return nil, p.errorf(closing, "expected )")
+		}
+
+		return n, nil
+
+	case tokEOF:
+		return nil, p.errorf(tok, "unexpected end of expression")
+
+	default:
+		return nil, p.errorf(tok, "unexpected %q", tok.text)
+	}
+}
+
+// fieldNames returns the known fields as a sorted, comma separated list.
+func (p *exprParser) fieldNames() string {
+	names := make([]string, 0, len(p.fields))
+	for name := range p.fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return strings.Join(names, ", ")
+}
+
+// filterExpr is a compiled --where expression.
+type filterExpr struct {
+	root *exprNode
+
+	// fields are the fields the expression refers to.
+	fields map[string]bool
+}
+
+// parseFilter compiles a --where expression over the given fields. An empty
+// expression matches everything.
+func parseFilter(src string, fields exprFields) (*filterExpr, error) {
+	if strings.TrimSpace(src) == "" {
+		return &filterExpr{}, nil
+	}
+
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &exprParser{
+		src:    src,
+		tokens: tokens,
+		fields: fields,
+		used:   make(map[string]bool),
+	}
+
+	start := p.peek()
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectBool(root, start); err != nil {
+		return nil, err
+	}
+
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, p.errorf(tok, "unexpected %q", tok.text)
+	}
+
+	return &filterExpr{root: root, fields: p.used}, nil
+}
+
+// uses returns true if the expression refers to any of the fields, so data
+// which is expensive to get is only fetched if it is needed.
+func (f *filterExpr) uses(fields ...string) bool {
+	for _, field := range fields {
+		if f.fields[field] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// match returns true if the record passes the filter.
+func (f *filterExpr) match(rec exprRecord) bool {
+	if f.root == nil {
+		return true
+	}
+
+	return f.root.eval(rec).flag
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+// testFields are the fields of the expressions under test.
+var testFields = exprFields{
+	"n": exprNumber,
+	"m": exprNumber,
+	"b": exprBool,
+	"s": exprString,
+}
+
+// testRecord is the row the expressions under test are matched against.
+func testRecord(field string) exprValue {
+	switch field {
+	case "n":
+		return exprValue{num: 5}
+	case "m":
+		return exprValue{num: 10}
+	case "b":
+		return exprValue{flag: true}
+	case "s":
+		return exprValue{str: "abc"}
+	}
+
+	return exprValue{}
+}
+
+// TestFilterMatch checks the result of valid expressions.
+func TestFilterMatch(t *testing.T) {
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		// An empty expression matches everything.
+		{src: "", want: true},
+		{src: "  ", want: true},
+
+		// Numbers.
+		{src: "n < m", want: true},
+		{src: "n <= 5", want: true},
+		{src: "n > 5", want: false},
+		{src: "n >= 5.0", want: true},
+		{src: "n == 5", want: true},
+		{src: "n != 5", want: false},
+		{src: "n < 5.5", want: true},
+		{src: "m > n", want: true},
+
+		// Strings, quoted either way.
+		{src: `s == "abc"`, want: true},
+		{src: `s == 'abc'`, want: true},
+		{src: `s != "abc"`, want: false},
+		{src: `s == "ab"`, want: false},
+		{src: `"abc" == s`, want: true},
+
+		// Booleans.
+		{src: "b", want: true},
+		{src: "!b", want: false},
+		{src: "!!b", want: true},
+		{src: "b == true", want: true},
+		{src: "b == false", want: false},
+		{src: "b != false", want: true},
+		{src: "false", want: false},
+
+		// && binds tighter than ||.
+		{src: "false && false || true", want: true},
+		{src: "true || false && false", want: true},
+		{src: "n > 1 || n > 2 && n > 100", want: true},
+
+		// Parentheses override it.
+		{src: "false && (false || true)", want: false},
+		{src: "(true || false) && false", want: false},
+		{src: "((n == 5))", want: true},
+
+		// ! applies to a whole comparison.
+		{src: "!n > 1", want: false},
+		{src: "!(n > 1) || b", want: true},
+		{src: "!(b && n > 1)", want: false},
+	}
+
+	for _, test := range tests {
+		f, err := parseFilter(test.src, testFields)
+		if err != nil {
+			t.Errorf("%q: %v", test.src, err)
+			continue
+		}
+
+		if got := f.match(testRecord); got != test.want {
+			t.Errorf("%q matched %v, want %v", test.src, got,
+				test.want)
+		}
+	}
+}
+
+// TestFilterErrors checks that invalid expressions are rejected with an
+// error underlining the offending token.
+func TestFilterErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{
+			src: "x > 1",
+			want: "unknown field \"x\", known fields are b, m, n, s\n" +
+				"  x > 1\n" +
+				"  ^",
+		},
+		{
+			src: "n > 1 && size < 2",
+			want: "unknown field \"size\", known fields are b, m, " +
+				"n, s\n" +
+				"  n > 1 && size < 2\n" +
+				"           ^^^^",
+		},
+		{
+			src: `n == "5"`,
+			want: "can't compare a number with a string\n" +
+				"  n == \"5\"\n" +
+				"       ^^^",
+		},
+		{
+			src: "b == 1",
+			want: "can't compare a boolean with a number\n" +
+				"  b == 1\n" +
+				"       ^",
+		},
+		{
+			src: `s < "b"`,
+			want: "operator < needs numbers, got a string\n" +
+				"  s < \"b\"\n" +
+				"    ^",
+		},
+		{
+			src: "b >= true",
+			want: "operator >= needs numbers, got a boolean\n" +
+				"  b >= true\n" +
+				"    ^^",
+		},
+		{
+			src: "n",
+			want: "expected a condition, got a number\n" +
+				"  n\n" +
+				"  ^",
+		},
+		{
+			src: "b && s",
+			want: "expected a condition, got a string\n" +
+				"  b && s\n" +
+				"       ^",
+		},
+		{
+			src: "n || b",
+			want: "expected a condition, got a number\n" +
+				"  n || b\n" +
+				"  ^",
+		},
+		{
+			src: "!m",
+			want: "expected a condition, got a number\n" +
+				"  !m\n" +
+				"   ^",
+		},
+		{
+			src: `s == "abc`,
+			want: "unterminated string\n" +
+				"  s == \"abc\n" +
+				"       ^^^^",
+		},
+		{
+			src: `s == 'abc"`,
+			want: "unterminated string\n" +
+				"  s == 'abc\"\n" +
+				"       ^^^^^",
+		},
+		{
+			src: "(n > 1",
+			want: "expected )\n" +
+				"  (n > 1\n" +
+				"        ^",
+		},
+		{
+			src: "n >",
+			want: "unexpected end of expression\n" +
+				"  n >\n" +
+				"     ^",
+		},
+		{
+			src: "n > 1 m",
+			want: "unexpected \"m\"\n" +
+				"  n > 1 m\n" +
+				"        ^",
+		},
+		{
+			src: "n # 1",
+			want: "unexpected character '#'\n" +
+				"  n # 1\n" +
+				"    ^",
+		},
+		{
+			src: "n > 1.2.3",
+			want: "invalid number \"1.2.3\"\n" +
+				"  n > 1.2.3\n" +
+				"      ^^^^^",
+		},
+	}
+
+	for _, test := range tests {
+		_, err := parseFilter(test.src, testFields)
+		if err == nil {
+			t.Errorf("%q was accepted", test.src)
+			continue
+		}
+
+		var exprErr *exprError
+		if !errors.As(err, &exprErr) {
+			t.Errorf("%q: got %T, want an *exprError", test.src, err)
+			continue
+		}
+
+		if got := err.Error(); got != test.want {
+			t.Errorf("%q:\ngot:\n%s\nwant:\n%s", test.src, got,
+				test.want)
+		}
+	}
+}
+
+// TestFilterUses checks that only the fields an expression refers to are
+// reported as used.
+func TestFilterUses(t *testing.T) {
+	f, err := parseFilter(`n > 1 && (s == "abc" || true)`, testFields)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for field, want := range map[string]bool{
+		"n": true, "s": true, "m": false, "b": false,
+	} {
+		if got := f.uses(field); got != want {
+			t.Errorf("uses(%q) = %v, want %v", field, got, want)
+		}
+	}
+
+	if !f.uses("m", "s") {
+		t.Error("uses(m, s) = false, want true")
+	}
+}
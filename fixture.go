@@ -0,0 +1,440 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/lnrpc/routerrpc"
+	"github.com/lightningnetwork/lnd/routing/route"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// The files of a fixture directory, one per RPC. They hold the responses as
+// lndclient returns them, encoded as JSON.
+const (
+	getInfoFixture           = "getinfo.json"
+	listChannelsFixture      = "listchannels.json"
+	forwardingHistoryFixture = "forwardinghistory.json"
+	nodeInfoFixture          = "nodeinfo.json"
+	chanInfoFixture          = "chaninfo.json"
+	closedChannelsFixture    = "closedchannels.json"
+	transactionsFixture      = "transactions.json"
+	paymentsFixture          = "payments.json"
+	pendingChannelsFixture   = "pendingchannels.json"
+	walletBalanceFixture     = "walletbalance.json"
+
+	// recordingFixture holds when the recording was made.
+	recordingFixture = "recording.json"
+
+	// htlcEventsFixture holds HTLC events the way watch htlcs records
+	// them, which are replayed as the event stream of the router.
+	htlcEventsFixture = "htlcevents.json"
+)
+
+// fixtureRecording describes a recording.
+type fixtureRecording struct {
+	// Time is when the recording was made.
+	Time time.Time `json:"time"`
+}
+
+// fixtureClient serves lnb's calls from recorded responses. It answers the
+// way lnd does, e.g. channels are filtered and forwarding history is paged,
+// so the commands behave exactly as against a live node.
+type fixtureClient struct {
+	recording fixtureRecording
+
+	info     *lndclient.Info
+	channels []lndclient.ChannelInfo
+	forwards []lndclient.ForwardingEvent
+
+	// nodes is keyed by the hex encoded public key.
+	nodes map[string]*lndclient.NodeInfo
+	edges map[uint64]*lndclient.ChannelEdge
+
+	closed       []lndclient.ClosedChannel
+	transactions []lndclient.Transaction
+	payments     []lndclient.Payment
+	pending      *lndclient.PendingChannels
+	wallet       *lndclient.WalletBalance
+
+	// htlcEvents are replayed as the event stream of the router.
+	htlcEvents []htlcEvent
+}
+
+// readFixture decodes the fixture file name in dir into v. A missing file
+// leaves v untouched, so a fixture only needs the files a command uses.
+func readFixture(dir, name string, v interface{}) error {
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil
+
+	case err != nil:
+		return fmt.Errorf("unable to read fixture: %w", err)
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("unable to decode fixture %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// writeFixture encodes v into the fixture file name in dir.
+func writeFixture(dir, name string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		return fmt.Errorf("unable to encode fixture %s: %w", name, err)
+	}
+
+	return os.WriteFile(filepath.Join(dir, name), append(data, '\n'), 0600)
+}
+
+// loadFixtures reads the fixture directory dir.
+func loadFixtures(dir string) (*fixtureClient, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("invalid fixture directory: %w", err)
+	}
+
+	f := &fixtureClient{
+		nodes: make(map[string]*lndclient.NodeInfo),
+		edges: make(map[uint64]*lndclient.ChannelEdge),
+	}
+
+	files := []struct {
+		name string
+		v    interface{}
+	}{
+		{recordingFixture, &f.recording},
+		{getInfoFixture, &f.info},
+		{listChannelsFixture, &f.channels},
+		{forwardingHistoryFixture, &f.forwards},
+		{nodeInfoFixture, &f.nodes},
+		{chanInfoFixture, &f.edges},
+		{closedChannelsFixture, &f.closed},
+		{transactionsFixture, &f.transactions},
+		{paymentsFixture, &f.payments},
+		{pendingChannelsFixture, &f.pending},
+		{walletBalanceFixture, &f.wallet},
+		{htlcEventsFixture, &f.htlcEvents},
+	}
+	for _, file := range files {
+		if err := readFixture(dir, file.name, file.v); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(f.forwards, func(i, j int) bool {
+		return f.forwards[i].Timestamp.Before(f.forwards[j].Timestamp)
+	})
+	sort.SliceStable(f.payments, func(i, j int) bool {
+		return f.payments[i].SequenceNumber < f.payments[j].SequenceNumber
+	})
+
+	return f, nil
+}
+
+// now returns the time the recording was made. Recordings which don't
+// tell fall back to the time of the best block, then to the wall clock.
+func (f *fixtureClient) now() time.Time {
+	switch {
+	case !f.recording.Time.IsZero():
+		return f.recording.Time
+
+	case f.info != nil && !f.info.BestHeaderTimeStamp.IsZero():
+		return f.info.BestHeaderTimeStamp
+
+	default:
+		return time.Now()
+	}
+}
+
+// GetInfo returns the recorded node info.
+func (f *fixtureClient) GetInfo(_ context.Context) (*lndclient.Info, error) {
+	if f.info == nil {
+		return nil, status.Errorf(codes.Unimplemented, "no %s fixture",
+			getInfoFixture)
+	}
+
+	info := *f.info
+	return &info, nil
+}
+
+// ListChannels returns the recorded channels which pass the filters of the
+// request.
+func (f *fixtureClient) ListChannels(_ context.Context, activeOnly,
+	publicOnly bool, opts ...lndclient.ListChannelsOption) (
+	[]lndclient.ChannelInfo, error) {
+
+	req := &lnrpc.ListChannelsRequest{
+		ActiveOnly: activeOnly,
+		PublicOnly: publicOnly,
+	}
+	for _, opt := range opts {
+		opt(req)
+	}
+
+	var channels []lndclient.ChannelInfo
+	for _, c := range f.channels {
+		switch {
+		case req.ActiveOnly && !c.Active,
+			req.InactiveOnly && c.Active,
+			req.PublicOnly && c.Private,
+			req.PrivateOnly && !c.Private,
+			len(req.Peer) > 0 && !bytes.Equal(req.Peer, c.PubKeyBytes[:]):
+
+			continue
+		}
+
+		channels = append(channels, c)
+	}
+
+	return channels, nil
+}
+
+// ForwardingHistory returns a page of the recorded forwarding events between
+// the start and end time of the request.
+func (f *fixtureClient) ForwardingHistory(_ context.Context,
+	req lndclient.ForwardingHistoryRequest) (
+	*lndclient.ForwardingHistoryResponse, error) {
+
+	end := req.EndTime
+	if end.IsZero() {
+		end = f.now()
+	}
+
+	var matching []lndclient.ForwardingEvent
+	for _, e := range f.forwards {
+		if !e.Timestamp.Before(req.StartTime) && e.Timestamp.Before(end) {
+			matching = append(matching, e)
+		}
+	}
+
+	maxEvents := int(req.MaxEvents)
+	if maxEvents == 0 {
+		maxEvents = defaultMaxEvents
+	}
+
+	offset := min(int(req.Offset), len(matching))
+	count := min(len(matching)-offset, maxEvents)
+
+	events := make([]lndclient.ForwardingEvent, count)
+	copy(events, matching[offset:offset+count])
+
+	return &lndclient.ForwardingHistoryResponse{
+		LastIndexOffset: uint32(offset + count),
+		Events:          events,
+	}, nil
+}
+
+// GetNodeInfo returns the recorded node, lnd's NotFound error if there is
+// none.
+func (f *fixtureClient) GetNodeInfo(_ context.Context, pubkey route.Vertex,
+	includeChannels bool) (*lndclient.NodeInfo, error) {
+
+	node, ok := f.nodes[hex.EncodeToString(pubkey[:])]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "unable to find node")
+	}
+
+	info := *node
+	if !includeChannels {
+		info.Channels = nil
+	}
+
+	return &info, nil
+}
+
+// GetChanInfo returns the recorded channel edge, lnd's NotFound error if
+// there is none.
+func (f *fixtureClient) GetChanInfo(_ context.Context, chanID uint64) (
+	*lndclient.ChannelEdge, error) {
+
+	edge, ok := f.edges[chanID]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "edge not found")
+	}
+
+	e := *edge
+	return &e, nil
+}
+
+// ClosedChannels returns the recorded closed channels.
+func (f *fixtureClient) ClosedChannels(_ context.Context) (
+	[]lndclient.ClosedChannel, error) {
+
+	closed := make([]lndclient.ClosedChannel, len(f.closed))
+	copy(closed, f.closed)
+
+	return closed, nil
+}
+
+// ListTransactions returns the recorded wallet transactions confirmed
+// between the two heights. The heights are derived from the confirmations
+// and the recorded block height, an end height of -1 includes unconfirmed
+// transactions.
+func (f *fixtureClient) ListTransactions(_ context.Context, startHeight,
+	endHeight int32, _ ...lndclient.ListTransactionsOption) (
+	[]lndclient.Transaction, error) {
+
+	var best int32
+	if f.info != nil {
+		best = int32(f.info.BlockHeight)
+	}
+
+	var txs []lndclient.Transaction
+	for _, tx := range f.transactions {
+		if tx.Confirmations == 0 {
+			if endHeight == -1 {
+				txs = append(txs, tx)
+			}
+			continue
+		}
+
+		height := best - tx.Confirmations + 1
+		if height < startHeight || (endHeight != -1 && height > endHeight) {
+			continue
+		}
+		txs = append(txs, tx)
+	}
+
+	return txs, nil
+}
+
+// ListPayments returns a page of the recorded payments after the offset of
+// the request. Payments count as complete if one of their HTLCs succeeded.
+// The creation date of a payment isn't recorded, the time of its first HTLC
+// attempt stands in for it.
+func (f *fixtureClient) ListPayments(_ context.Context,
+	req lndclient.ListPaymentsRequest) (*lndclient.ListPaymentsResponse,
+	error) {
+
+	maxPayments := int(req.MaxPayments)
+	if maxPayments == 0 {
+		maxPayments = defaultMaxPayments
+	}
+
+	resp := &lndclient.ListPaymentsResponse{}
+	for _, p := range f.payments {
+		if p.SequenceNumber <= req.Offset {
+			continue
+		}
+		if !req.IncludeIncomplete && !paymentSucceeded(p) {
+			continue
+		}
+		if !req.CreationDateStart.IsZero() &&
+			paymentCreated(p).Before(req.CreationDateStart) {
+
+			continue
+		}
+		if !req.CreationDateEnd.IsZero() &&
+			paymentCreated(p).After(req.CreationDateEnd) {
+
+			continue
+		}
+		if len(resp.Payments) == maxPayments {
+			break
+		}
+
+		if len(resp.Payments) == 0 {
+			resp.FirstIndexOffset = p.SequenceNumber
+		}
+		resp.LastIndexOffset = p.SequenceNumber
+		resp.Payments = append(resp.Payments, p)
+	}
+
+	return resp, nil
+}
+
+// paymentCreated returns the time of the first HTLC attempt of a payment,
+// the zero time if it has none.
+func paymentCreated(p lndclient.Payment) time.Time {
+	var created time.Time
+	for _, htlc := range p.Htlcs {
+		t := time.Unix(0, htlc.AttemptTimeNs)
+		if created.IsZero() || t.Before(created) {
+			created = t
+		}
+	}
+
+	return created
+}
+
+// PendingChannels returns the recorded pending channels, none if there is
+// no recording of them.
+func (f *fixtureClient) PendingChannels(_ context.Context) (
+	*lndclient.PendingChannels, error) {
+
+	if f.pending == nil {
+		return &lndclient.PendingChannels{}, nil
+	}
+
+	pending := *f.pending
+	return &pending, nil
+}
+
+// WalletBalance returns the recorded wallet balance, an empty wallet if
+// there is no recording of it.
+func (f *fixtureClient) WalletBalance(_ context.Context) (
+	*lndclient.WalletBalance, error) {
+
+	if f.wallet == nil {
+		return &lndclient.WalletBalance{}, nil
+	}
+
+	wallet := *f.wallet
+	return &wallet, nil
+}
+
+// SubscribeHtlcEvents replays the recorded HTLC events, oldest first. The
+// stream ends once all of them are sent.
+func (f *fixtureClient) SubscribeHtlcEvents(ctx context.Context) (
+	<-chan *routerrpc.HtlcEvent, <-chan error, error) {
+
+	events := make(chan *routerrpc.HtlcEvent)
+	errs := make(chan error, 1)
+
+	recorded := make([]htlcEvent, len(f.htlcEvents))
+	copy(recorded, f.htlcEvents)
+	sort.SliceStable(recorded, func(i, j int) bool {
+		return recorded[i].Time.Before(recorded[j].Time)
+	})
+
+	go func() {
+		defer close(events)
+
+		for _, e := range recorded {
+			select {
+			case events <- e.rpcEvent():
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return events, errs, nil
+}
+
+// SubscribeChannelEvents returns a stream which ends at once. Channel
+// events aren't kept in lnb's history, so a recording has none of them and
+// watch channels only checks the recorded channels.
+func (f *fixtureClient) SubscribeChannelEvents(_ context.Context) (
+	<-chan *lndclient.ChannelEventUpdate, <-chan error, error) {
+
+	updates := make(chan *lndclient.ChannelEventUpdate)
+	close(updates)
+
+	return updates, make(chan error, 1), nil
+}
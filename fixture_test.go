@@ -0,0 +1,383 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// update rewrites the golden files with the output of the current code,
+// run go test -run TestGolden -update after a deliberate change of output.
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+// runLnb runs lnb with the global and command args and returns what it
+// printed to stdout. It is kept away from the user's config and history, its
+// --lnbdir starts out as a copy of lnbDir if that is set.
+func runLnb(t *testing.T, lnbDir string, args ...string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	if lnbDir != "" {
+		if err := os.CopyFS(dir, os.DirFS(lnbDir)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	config := filepath.Join(dir, defaultConfigFilename)
+	if err := os.WriteFile(config, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := make(chan []byte)
+	go func() {
+		data, _ := io.ReadAll(r)
+		output <- data
+	}()
+
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() {
+		os.Stdout = stdout
+	}()
+
+	args = append([]string{
+		"lnb", "--configfile", config, "--lnbdir", dir,
+	}, args...)
+	err = newApp().RunContext(context.Background(), args)
+
+	w.Close()
+	data := <-output
+	if err != nil {
+		t.Fatalf("lnb %v: %v", args[1:], err)
+	}
+
+	return string(data)
+}
+
+// TestGolden runs commands against the recordings in testdata/fixtures and
+// compares their output with testdata/golden. The history lnb recorded of a
+// node, if any, is in the lnbdir of its recording.
+func TestGolden(t *testing.T) {
+	tests := []struct {
+		name    string
+		fixture string
+		args    []string
+	}{
+		{
+			name:    "balance",
+			fixture: "node",
+			args:    []string{"get", "balance"},
+		},
+		{
+			name:    "balance_active_btc",
+			fixture: "node",
+			args:    []string{"--unit", "btc", "get", "balance", "--active"},
+		},
+		{
+			name:    "balance_empty",
+			fixture: "empty",
+			args:    []string{"get", "balance"},
+		},
+		{
+			name:    "channels",
+			fixture: "node",
+			args:    []string{"list", "channels"},
+		},
+		{
+			name:    "channels_columns",
+			fixture: "node",
+			args: []string{
+				"list", "channels", "--columns",
+				"id,alias,capacity,ratio", "--sort", "capacity:desc",
+			},
+		},
+		{
+			name:    "channels_sort_id_scid",
+			fixture: "node",
+			args: []string{
+				"--chan-format", "scid", "list", "channels",
+				"--columns", "id,capacity", "--sort", "id:asc",
+				"--output", "csv",
+			},
+		},
+		{
+			name:    "channels_where_alias",
+			fixture: "node",
+			args: []string{
+				"list", "channels", "--columns", "id,peer,alias",
+				"--where", `alias == "alpha"`,
+			},
+		},
+		{
+			name:    "channels_empty",
+			fixture: "empty",
+			args:    []string{"list", "channels"},
+		},
+		{
+			name:    "profit",
+			fixture: "node",
+			args: []string{
+				"report", "profit", "--period", "monthly",
+				"--since", "2026-01-01", "--tz", "UTC",
+			},
+		},
+		{
+			name:    "contracts",
+			fixture: "node",
+			args:    []string{"list", "contracts", "--tz", "UTC"},
+		},
+		{
+			name:    "contracts_since",
+			fixture: "node",
+			args: []string{
+				"--chan-format", "scid", "list", "contracts",
+				"--since", "3d", "--tz", "UTC",
+			},
+		},
+		{
+			name:    "contracts_closed_peer",
+			fixture: "node",
+			args: []string{
+				"list", "contracts", "--since", "2026-09-01",
+				"--tz", "UTC", "--peer",
+				"03" + strings.Repeat("0d", 32),
+			},
+		},
+		{
+			name:    "contracts_closed_chanpoint",
+			fixture: "node",
+			args: []string{
+				"list", "contracts", "--since", "2026-09-01",
+				"--tz", "UTC", "--channel",
+				strings.Repeat("00", 31) + "aa:1",
+			},
+		},
+		{
+			name:    "contracts_empty",
+			fixture: "empty",
+			args:    []string{"list", "contracts", "--tz", "UTC"},
+		},
+		{
+			name:    "roi",
+			fixture: "node",
+			args:    []string{"report", "roi"},
+		},
+		{
+			name:    "roi_empty",
+			fixture: "empty",
+			args:    []string{"report", "roi"},
+		},
+		{
+			name:    "rebalances",
+			fixture: "node",
+			args:    []string{"list", "rebalances", "--tz", "UTC"},
+		},
+		{
+			name:    "rebalances_channel",
+			fixture: "node",
+			args: []string{
+				"--chan-format", "scid", "list", "rebalances",
+				"--since", "2026-10-01", "--tz", "UTC",
+				"--channel", "800002x102x1",
+			},
+		},
+		{
+			name:    "channels_net_fee",
+			fixture: "node",
+			args: []string{
+				"list", "channels", "--columns",
+				"id,month_fee,month_net_fee",
+			},
+		},
+		{
+			name:    "flows",
+			fixture: "node",
+			args:    []string{"report", "flows", "--tz", "UTC"},
+		},
+		{
+			name:    "flows_matrix",
+			fixture: "node",
+			args: []string{
+				"report", "flows", "--tz", "UTC", "--matrix",
+			},
+		},
+		{
+			name:    "flows_peer_matrix",
+			fixture: "node",
+			args: []string{
+				"report", "flows", "--since", "2026-09-01",
+				"--tz", "UTC", "--by", "peer", "--matrix",
+				"--limit", "3",
+			},
+		},
+		{
+			name:    "idle",
+			fixture: "node",
+			args:    []string{"report", "idle"},
+		},
+		{
+			name:    "idle_fee_rate",
+			fixture: "node",
+			args: []string{
+				"--chan-format", "scid", "report", "idle",
+				"--days", "60", "--sat-per-vbyte", "10",
+			},
+		},
+		{
+			name:    "history",
+			fixture: "node",
+			args: []string{
+				"report", "history", "--since", "30d", "--tz",
+				"UTC", "--channel", "800001:101:1",
+				"--channel", "800000:100:1",
+			},
+		},
+		{
+			name:    "history_window",
+			fixture: "node",
+			args: []string{
+				"--unit", "btc", "report", "history",
+				"--since", "2026-10-10", "--until",
+				"2026-10-15", "--tz", "UTC", "--channel",
+				"800002x102x1",
+			},
+		},
+		{
+			name:    "channels_trend",
+			fixture: "node",
+			args: []string{
+				"list", "channels", "--columns",
+				"id,ratio,trend",
+			},
+		},
+		{
+			name:    "channels_eta",
+			fixture: "node",
+			args: []string{
+				"list", "channels", "--columns",
+				"id,local,remote,month_in,month_out,eta_empty,eta_full",
+			},
+		},
+		{
+			name:    "channels_eta_drain_days",
+			fixture: "node",
+			args: []string{
+				"list", "channels", "--columns",
+				"id,eta_empty,eta_full", "--drain-days", "60",
+			},
+		},
+		{
+			name:    "htlc_sizes",
+			fixture: "node",
+			args:    []string{"report", "htlc-sizes", "--tz", "UTC"},
+		},
+		{
+			name:    "htlc_sizes_histogram",
+			fixture: "node",
+			args: []string{
+				"report", "htlc-sizes", "--tz", "UTC",
+				"--histogram",
+			},
+		},
+		{
+			name:    "htlc_sizes_histogram_channel",
+			fixture: "node",
+			args: []string{
+				"--unit", "msat", "report", "htlc-sizes",
+				"--since", "2026-09-01", "--tz", "UTC",
+				"--histogram", "--channel", "790000:50:1",
+			},
+		},
+		{
+			name:    "activity",
+			fixture: "node",
+			args:    []string{"report", "activity", "--tz", "UTC"},
+		},
+		{
+			name:    "activity_fees_channel",
+			fixture: "node",
+			args: []string{
+				"report", "activity", "--metric", "fees",
+				"--since", "2026-09-01", "--tz", "UTC",
+				"--channel", "800000:100:1", "--channel",
+				strings.Repeat("00", 31) + "aa:1",
+			},
+		},
+		{
+			name:    "htlc_risk",
+			fixture: "node",
+			args:    []string{"report", "htlc-risk"},
+		},
+		{
+			name:    "htlc_risk_channels",
+			fixture: "node",
+			args: []string{
+				"report", "htlc-risk", "--blocks", "50",
+				"--alias", "--channel", "800000:100:1",
+				"--channel", "800001x101x1",
+			},
+		},
+		{
+			name:    "htlc_risk_empty",
+			fixture: "empty",
+			args:    []string{"report", "htlc-risk"},
+		},
+		{
+			name:    "jamming",
+			fixture: "node",
+			args:    []string{"report", "jamming", "--tz", "UTC"},
+		},
+		{
+			name:    "jamming_until",
+			fixture: "node",
+			args: []string{
+				"report", "jamming", "--tz", "UTC", "--until",
+				"2026-10-19T04:00:00Z", "--hold", "30s",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fixture := filepath.Join(
+				"testdata", "fixtures", test.fixture,
+			)
+			lnbDir := filepath.Join(fixture, "lnbdir")
+			if _, err := os.Stat(lnbDir); err != nil {
+				lnbDir = ""
+			}
+
+			backend := fixtureBackendPrefix + fixture
+			got := runLnb(t, lnbDir, append(
+				[]string{"--backend", backend}, test.args...,
+			)...)
+
+			golden := filepath.Join(
+				"testdata", "golden", test.name+".txt",
+			)
+			if *update {
+				err := os.WriteFile(golden, []byte(got), 0644)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != string(want) {
+				t.Errorf("output differs from %s\ngot:\n%s\nwant:\n%s",
+					golden, got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,450 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/routing/route"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// The ways report flows can group forwards, see --by.
+	flowsByChannel = "channel"
+	flowsByPeer    = "peer"
+
+	// defaultMatrixSize is the number of channels or peers the matrix
+	// shows on each axis unless --limit says otherwise.
+	defaultMatrixSize = 10
+)
+
+// heatShades are the glyphs which mark the matrix cells from the least to
+// the most volume.
+var heatShades = []string{"░", "▒", "▓", "█"}
+
+// flowEnd is where a forward entered or left our node: a channel, or a peer
+// if forwards are grouped by peer.
+type flowEnd struct {
+	chanID uint64
+	peer   route.Vertex
+}
+
+// flowKey identifies the forwards from one end to another.
+type flowKey struct {
+	in  flowEnd
+	out flowEnd
+}
+
+// flow sums up the forwards between two ends.
+type flow struct {
+	flowKey
+
+	count  int
+	volume lnwire.MilliSatoshi
+	fee    lnwire.MilliSatoshi
+}
+
+// flowLabels names the ends of flows.
+type flowLabels struct {
+	chanFmt chanFormat
+	aliases map[route.Vertex]string
+}
+
+// peer returns the alias of a peer if it is known, the start of its public
+// key otherwise.
+func (l *flowLabels) peer(peer route.Vertex) string {
+	if peer == (route.Vertex{}) {
+		return "unknown"
+	}
+
+	if alias, ok := l.aliases[peer]; ok && alias != "" {
+		return truncate(alias, 10)
+	}
+
+	return hex.EncodeToString(peer[:4])
+}
+
+// end names an end, its channel if it has one.
+func (l *flowLabels) end(e flowEnd) string {
+	if e.chanID != 0 {
+		return l.chanFmt.format(e.chanID)
+	}
+
+	return l.peer(e.peer)
+}
+
+// channelPeers returns the peers of our open and closed channels.
+func channelPeers(ctx context.Context,
+	client lndAPI) (map[uint64]route.Vertex, error) {
+
+	var (
+		channels []lndclient.ChannelInfo
+		closed   []lndclient.ClosedChannel
+	)
+	g, gctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		var err error
+		channels, err = client.ListChannels(gctx, false, false)
+		return err
+	})
+	g.Go(func() error {
+		var err error
+		closed, err = client.ClosedChannels(gctx)
+		return err
+	})
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	peers := make(map[uint64]route.Vertex, len(channels)+len(closed))
+	for _, c := range closed {
+		peers[c.ChannelID] = c.PubKeyBytes
+	}
+	for _, c := range channels {
+		peers[c.ChannelID] = c.PubKeyBytes
+	}
+
+	return peers, nil
+}
+
+// sumFlows groups the forwarding events by their incoming and outgoing
+// channel, or by peer, and returns the flows by descending volume.
+func sumFlows(events []lndclient.ForwardingEvent,
+	peers map[uint64]route.Vertex, byPeer bool) []*flow {
+
+	end := func(chanID uint64) flowEnd {
+		if byPeer {
+			return flowEnd{peer: peers[chanID]}
+		}
+
+		return flowEnd{chanID: chanID, peer: peers[chanID]}
+	}
+
+	flows := make(map[flowKey]*flow)
+	for _, e := range events {
+		key := flowKey{in: end(e.ChannelIn), out: end(e.ChannelOut)}
+
+		f, ok := flows[key]
+		if !ok {
+			f = &flow{flowKey: key}
+			flows[key] = f
+		}
+
+		f.count++
+		f.volume += e.AmountMsatOut
+		f.fee += e.FeeMsat
+	}
+
+	sorted := make([]*flow, 0, len(flows))
+	for _, f := range flows {
+		sorted = append(sorted, f)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].volume != sorted[j].volume {
+			return sorted[i].volume > sorted[j].volume
+		}
+
+		return sorted[i].count > sorted[j].count
+	})
+
+	return sorted
+}
+
+// reportFlows prints the forwarding volume between pairs of channels or
+// peers, as a list or as a matrix.
+func reportFlows(ctx *cli.Context) error {
+	ctxb := ctx.Context
+	client, err := getClient(ctxb, ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to LND: %w", err)
+	}
+	defer client.Close()
+
+	chanFmt, err := getChanFormat(ctx)
+	if err != nil {
+		return err
+	}
+
+	unit, err := getAmountUnit(ctx)
+	if err != nil {
+		return err
+	}
+
+	by := ctx.String("by")
+	if by != flowsByChannel && by != flowsByPeer {
+		return fmt.Errorf("invalid --by %q, should be %s or %s", by,
+			flowsByChannel, flowsByPeer)
+	}
+
+	limit := ctx.Int("limit")
+	if limit < 0 {
+		return fmt.Errorf("invalid --limit %d", limit)
+	}
+
+	loc, err := time.LoadLocation(ctx.String("tz"))
+	if err != nil {
+		return fmt.Errorf("invalid --tz: %w", err)
+	}
+	now := client.Now()
+
+	start, err := parseTimeSpec(ctx.String("since"), now, loc)
+	if err != nil {
+		return fmt.Errorf("invalid --since: %w", err)
+	}
+
+	end := now
+	if ctx.IsSet("until") {
+		end, err = parseTimeSpec(ctx.String("until"), now, loc)
+		if err != nil {
+			return fmt.Errorf("invalid --until: %w", err)
+		}
+	}
+
+	var (
+		events []lndclient.ForwardingEvent
+		peers  map[uint64]route.Vertex
+	)
+	g, gctx := errgroup.WithContext(ctxb)
+	g.Go(func() error {
+		var err error
+		events, err = fetchForwards(gctx, client.Client, start, end)
+		return err
+	})
+	g.Go(func() error {
+		var err error
+		peers, err = channelPeers(gctx, client.Client)
+		return err
+	})
+	if err := g.Wait(); err != nil {
+		return err
+	}
+
+	flows := sumFlows(events, peers, by == flowsByPeer)
+
+	labels := &flowLabels{chanFmt: chanFmt}
+	if ctx.Bool("alias") {
+		var nodes []route.Vertex
+		for _, p := range peers {
+			nodes = append(nodes, p)
+		}
+
+		labels.aliases, err = lookupAliases(ctxb, client.Client, nodes)
+		if err != nil {
+			return err
+		}
+	}
+
+	if ctx.Bool("matrix") {
+		if limit == 0 {
+			limit = defaultMatrixSize
+		}
+		printFlowMatrix(flows, limit, labels, unit)
+
+		return nil
+	}
+
+	printFlows(flows, limit, by == flowsByPeer, labels, unit)
+
+	return nil
+}
+
+// printFlows prints the flows, at most limit of them if it isn't zero,
+// followed by the totals of all of them.
+func printFlows(flows []*flow, limit int, byPeer bool, labels *flowLabels,
+	unit amountUnit) {
+
+	headers := []string{
+		"Num", "In Channel", "In Peer", "Out Channel", "Out Peer",
+		"Forwards", "Volume", "Fees", "PPM",
+	}
+	left := []int{2, 4}
+	if byPeer {
+		headers = []string{
+			"Num", "In Peer", "Out Peer", "Forwards", "Volume",
+			"Fees", "PPM",
+		}
+		left = []int{1, 2}
+	}
+
+	table := newTextTable(headers...)
+	table.alignLeft(left...)
+
+	var total flow
+	for i, f := range flows {
+		total.count += f.count
+		total.volume += f.volume
+		total.fee += f.fee
+
+		if limit > 0 && i >= limit {
+			continue
+		}
+
+		row := []string{fmt.Sprint(i + 1)}
+		if byPeer {
+			row = append(row, labels.peer(f.in.peer),
+				labels.peer(f.out.peer))
+		} else {
+			row = append(row,
+				labels.chanFmt.format(f.in.chanID),
+				labels.peer(f.in.peer),
+				labels.chanFmt.format(f.out.chanID),
+				labels.peer(f.out.peer),
+			)
+		}
+		row = append(row,
+			fmt.Sprint(f.count),
+			unit.format(f.volume),
+			unit.format(f.fee),
+			fmt.Sprint(ppm(f.fee, f.volume)),
+		)
+		table.addRow(row...)
+	}
+
+	footer := []string{fmt.Sprint(len(flows)), "", ""}
+	if !byPeer {
+		footer = append(footer, "", "")
+	}
+	footer = append(footer,
+		fmt.Sprint(total.count),
+		unit.format(total.volume),
+		unit.format(total.fee),
+		fmt.Sprint(ppm(total.fee, total.volume)),
+	)
+	table.setFooter(footer...)
+
+	table.print()
+}
+
+// printFlowMatrix prints the volume between the size busiest ends as a
+// matrix, incoming ends as rows and outgoing ends as columns. The other ends
+// are summed up in a last row and column. Each cell is shaded by its share
+// of the largest one.
+func printFlowMatrix(flows []*flow, size int, labels *flowLabels,
+	unit amountUnit) {
+
+	busy := make(map[flowEnd]lnwire.MilliSatoshi)
+	for _, f := range flows {
+		busy[f.in] += f.volume
+		busy[f.out] += f.volume
+	}
+
+	rank := func(ends map[flowEnd]bool) ([]flowEnd, bool) {
+		sorted := make([]flowEnd, 0, len(ends))
+		for e := range ends {
+			sorted = append(sorted, e)
+		}
+		sort.Slice(sorted, func(i, j int) bool {
+			if busy[sorted[i]] != busy[sorted[j]] {
+				return busy[sorted[i]] > busy[sorted[j]]
+			}
+
+			return labels.end(sorted[i]) < labels.end(sorted[j])
+		})
+
+		if len(sorted) <= size {
+			return sorted, false
+		}
+
+		return sorted[:size], true
+	}
+
+	ins, outs := make(map[flowEnd]bool), make(map[flowEnd]bool)
+	for _, f := range flows {
+		ins[f.in] = true
+		outs[f.out] = true
+	}
+	rows, otherRows := rank(ins)
+	cols, otherCols := rank(outs)
+
+	// Ends which didn't make it onto an axis go to the last row or
+	// column.
+	index := func(axis []flowEnd, e flowEnd) int {
+		for i, a := range axis {
+			if a == e {
+				return i
+			}
+		}
+
+		return len(axis)
+	}
+
+	numRows, numCols := len(rows), len(cols)
+	if otherRows {
+		numRows++
+	}
+	if otherCols {
+		numCols++
+	}
+
+	cells := make([][]lnwire.MilliSatoshi, numRows)
+	for i := range cells {
+		cells[i] = make([]lnwire.MilliSatoshi, numCols)
+	}
+
+	var largest lnwire.MilliSatoshi
+	for _, f := range flows {
+		i, j := index(rows, f.in), index(cols, f.out)
+		cells[i][j] += f.volume
+		largest = max(largest, cells[i][j])
+	}
+
+	formatCell := func(v lnwire.MilliSatoshi) string {
+		if v == 0 {
+			return "-"
+		}
+
+		shade := int(math.Ceil(
+			float64(v) / float64(largest) * float64(len(heatShades)),
+		))
+		shade = min(max(shade, 1), len(heatShades))
+
+		return heatShades[shade-1] + " " + unit.format(v)
+	}
+
+	headers := []string{"In \\ Out"}
+	for _, c := range cols {
+		headers = append(headers, labels.end(c))
+	}
+	if otherCols {
+		headers = append(headers, "other")
+	}
+	headers = append(headers, "Total")
+
+	table := newTextTable(headers...)
+	table.alignLeft(0)
+
+	colTotals := make([]lnwire.MilliSatoshi, numCols)
+	var total lnwire.MilliSatoshi
+	for i, cellRow := range cells {
+		label := "other"
+		if i < len(rows) {
+			label = labels.end(rows[i])
+		}
+
+		row := []string{label}
+		var rowTotal lnwire.MilliSatoshi
+		for j, v := range cellRow {
+			row = append(row, formatCell(v))
+			rowTotal += v
+			colTotals[j] += v
+		}
+		total += rowTotal
+
+		table.addRow(append(row, unit.format(rowTotal))...)
+	}
+
+	footer := []string{"Total"}
+	for _, v := range colTotals {
+		footer = append(footer, unit.format(v))
+	}
+	table.setFooter(append(footer, unit.format(total))...)
+
+	table.print()
+}
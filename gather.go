@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/routing/route"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// forwardsPageSize is the maximum number of events lnd returns for a
+	// single forwarding history call.
+	forwardsPageSize = 50000
+
+	// defaultMaxEvents is the number of forwarding events lnd returns if
+	// the request doesn't limit them.
+	defaultMaxEvents = 100
+
+	// forwardsWindows is the number of time windows a forwarding history
+	// query is split into, so the windows can be fetched concurrently.
+	forwardsWindows = 16
+
+	// minForwardsWindow keeps short queries from being split into tiny
+	// windows which would only add round trips.
+	minForwardsWindow = time.Hour
+)
+
+// fetchForwards returns all forwarding events between start and end, oldest
+// first. The range is split into windows which are paged through
+// concurrently, the number of calls actually in flight is limited by the
+// client.
+func fetchForwards(ctx context.Context, client lndAPI,
+	start, end time.Time) ([]lndclient.ForwardingEvent, error) {
+
+	step := max(end.Sub(start)/forwardsWindows, minForwardsWindow)
+
+	var windows []time.Time
+	for t := start; t.Before(end); t = t.Add(step) {
+		windows = append(windows, t)
+	}
+
+	results := make([][]lndclient.ForwardingEvent, len(windows))
+	g, gctx := errgroup.WithContext(ctx)
+	for i, from := range windows {
+		to := from.Add(step)
+		if to.After(end) {
+			to = end
+		}
+
+		g.Go(func() error {
+			events, err := fetchForwardsWindow(gctx, client, from, to)
+			results[i] = events
+			return err
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	var events []lndclient.ForwardingEvent
+	for _, r := range results {
+		events = append(events, r...)
+	}
+
+	return events, nil
+}
+
+// fetchForwardsWindow pages through the forwarding history between start
+// and end.
+func fetchForwardsWindow(ctx context.Context, client lndAPI,
+	start, end time.Time) ([]lndclient.ForwardingEvent, error) {
+
+	var (
+		events []lndclient.ForwardingEvent
+		offset uint32
+	)
+	for {
+		resp, err := client.ForwardingHistory(
+			ctx, lndclient.ForwardingHistoryRequest{
+				StartTime: start,
+				EndTime:   end,
+				Offset:    offset,
+				MaxEvents: forwardsPageSize,
+			},
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, resp.Events...)
+		if len(resp.Events) < forwardsPageSize {
+			return events, nil
+		}
+		offset = resp.LastIndexOffset
+	}
+}
+
+// lookupAliases fetches the aliases of the given nodes concurrently. Nodes
+// which are not in our graph, e.g. private peers, are left out.
+func lookupAliases(ctx context.Context, client lndAPI,
+	nodes []route.Vertex) (map[route.Vertex]string, error) {
+
+	unique := make(map[route.Vertex]struct{}, len(nodes))
+	for _, node := range nodes {
+		unique[node] = struct{}{}
+	}
+
+	aliases := make(map[route.Vertex]string, len(unique))
+	found := make(chan *lndclient.NodeInfo, len(unique))
+
+	g, gctx := errgroup.WithContext(ctx)
+	for node := range unique {
+		g.Go(func() error {
+			info, err := client.GetNodeInfo(gctx, node, false)
+			switch {
+			case status.Code(err) == codes.NotFound:
+				return nil
+
+			case err != nil:
+				return err
+			}
+
+			found <- info
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	close(found)
+
+	for info := range found {
+		if info.Node != nil {
+			aliases[info.PubKey] = info.Alias
+		}
+	}
+
+	return aliases, nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"time"
+)
+
+const (
+	// hookScriptTimeout is the time an alert script may run before it is
+	// killed.
+	hookScriptTimeout = 30 * time.Second
+
+	// maxHookResponse is the most of a webhook response which is read.
+	maxHookResponse = 1 << 16
+)
+
+// alertHooks hands alerts to the webhook and script they are configured
+// with. Either of them may be empty.
+type alertHooks struct {
+	webhook string
+	script  string
+	client  *http.Client
+}
+
+// configured returns whether any hook is set.
+func (h *alertHooks) configured() bool {
+	return h.webhook != "" || h.script != ""
+}
+
+// run posts the alert as JSON to the webhook and feeds it to the script on
+// stdin. Both are tried even if one of them fails.
+func (h *alertHooks) run(ctx context.Context, alert interface{}) error {
+	data, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	if h.webhook != "" {
+		if err := h.post(ctx, data); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if h.script != "" {
+		if err := h.exec(ctx, data); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// post sends the alert to the webhook, which has to answer with a 2xx
+// status.
+func (h *alertHooks) post(ctx context.Context, data []byte) error {
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodPost, h.webhook, bytes.NewReader(data),
+	)
+	if err != nil {
+		return fmt.Errorf("invalid webhook: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to call webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Reading the response lets the connection be reused.
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxHookResponse))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook %s answered %s", h.webhook,
+			resp.Status)
+	}
+
+	return nil
+}
+
+// exec runs the script with the alert on stdin. Its output goes to lnb's.
+func (h *alertHooks) exec(ctx context.Context, data []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, hookScriptTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, cleanAndExpandPath(h.script))
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("alert script %s failed: %w", h.script, err)
+	}
+
+	return nil
+}
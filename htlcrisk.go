@@ -0,0 +1,264 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/routing/route"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// The blocks before its expiry at which lnd goes on chain to resolve
+	// an HTLC, lnd's default incoming and outgoing broadcast deltas.
+	incomingBroadcastDelta = 10
+	outgoingBroadcastDelta = 0
+
+	// defaultRiskBlocks is the number of blocks before the force close
+	// within which an HTLC is flagged unless --blocks says otherwise.
+	defaultRiskBlocks = 36
+)
+
+// inFlightHTLC is an HTLC pending on an open channel.
+type inFlightHTLC struct {
+	chanID uint64
+	peer   route.Vertex
+	lndclient.PendingHtlc
+}
+
+// blocksLeft returns the number of blocks until the HTLC expires.
+func (h *inFlightHTLC) blocksLeft(height uint32) int64 {
+	return int64(h.Expiry) - int64(height)
+}
+
+// deadline returns the number of blocks until lnd force closes the channel
+// to resolve the HTLC on chain.
+func (h *inFlightHTLC) deadline(height uint32) int64 {
+	if h.Incoming {
+		return h.blocksLeft(height) - incomingBroadcastDelta
+	}
+
+	return h.blocksLeft(height) - outgoingBroadcastDelta
+}
+
+// slotUsage is the number of HTLC slots a channel has in use in each
+// direction, out of the number its constraints allow.
+type slotUsage struct {
+	chanID uint64
+	peer   route.Vertex
+
+	in, out       int
+	maxIn, maxOut uint32
+	amtIn, amtOut btcutil.Amount
+}
+
+// share returns the share of the fuller direction in percent.
+func (s *slotUsage) share() float64 {
+	var share float64
+	if s.maxIn > 0 {
+		share = float64(s.in) / float64(s.maxIn) * 100
+	}
+	if s.maxOut > 0 {
+		share = max(share, float64(s.out)/float64(s.maxOut)*100)
+	}
+
+	return share
+}
+
+// newSlotUsage counts the slots of a channel in use. Incoming HTLCs take
+// the slots we accept, outgoing ones those the peer accepts.
+func newSlotUsage(c *lndclient.ChannelInfo) *slotUsage {
+	s := &slotUsage{chanID: c.ChannelID, peer: c.PubKeyBytes}
+	if c.LocalConstraints != nil {
+		s.maxIn = c.LocalConstraints.MaxAcceptedHtlcs
+	}
+	if c.RemoteConstraints != nil {
+		s.maxOut = c.RemoteConstraints.MaxAcceptedHtlcs
+	}
+
+	for _, h := range c.PendingHtlcs {
+		if h.Incoming {
+			s.in++
+			s.amtIn += h.Amount
+		} else {
+			s.out++
+			s.amtOut += h.Amount
+		}
+	}
+
+	return s
+}
+
+// reportHTLCRisk prints the HTLCs in flight by how close they are to the
+// force close which resolves them, and the HTLC slots each channel uses.
+func reportHTLCRisk(ctx *cli.Context) error {
+	ctxb := ctx.Context
+	client, err := getClient(ctxb, ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to LND: %w", err)
+	}
+	defer client.Close()
+
+	chanFmt, err := getChanFormat(ctx)
+	if err != nil {
+		return err
+	}
+
+	unit, err := getAmountUnit(ctx)
+	if err != nil {
+		return err
+	}
+
+	riskBlocks := ctx.Int64("blocks")
+	if riskBlocks < 0 {
+		return fmt.Errorf("--blocks can't be negative")
+	}
+
+	filter := make(map[uint64]bool)
+	ids, err := resolveChanIDs(ctxb, client.Client, ctx.StringSlice("channel"))
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		filter[id] = true
+	}
+
+	var (
+		info     *lndclient.Info
+		channels []lndclient.ChannelInfo
+	)
+	g, gctx := errgroup.WithContext(ctxb)
+	g.Go(func() error {
+		var err error
+		info, err = client.Client.GetInfo(gctx)
+		return err
+	})
+	g.Go(func() error {
+		var err error
+		channels, err = client.Client.ListChannels(gctx, false, false)
+		return err
+	})
+	if err := g.Wait(); err != nil {
+		return err
+	}
+
+	var (
+		htlcs []inFlightHTLC
+		slots []*slotUsage
+		peers []route.Vertex
+	)
+	for i := range channels {
+		c := &channels[i]
+		if len(filter) > 0 && !filter[c.ChannelID] ||
+			len(c.PendingHtlcs) == 0 {
+
+			continue
+		}
+
+		for _, h := range c.PendingHtlcs {
+			htlcs = append(htlcs, inFlightHTLC{
+				chanID:      c.ChannelID,
+				peer:        c.PubKeyBytes,
+				PendingHtlc: h,
+			})
+		}
+		slots = append(slots, newSlotUsage(c))
+		peers = append(peers, c.PubKeyBytes)
+	}
+
+	height := info.BlockHeight
+	var atRisk int
+	for i := range htlcs {
+		if htlcs[i].deadline(height) <= riskBlocks {
+			atRisk++
+		}
+	}
+
+	fmt.Printf("%d HTLCs in flight at block %d, %d within %d blocks of "+
+		"their force close\n", len(htlcs), height, atRisk, riskBlocks)
+	if len(htlcs) == 0 {
+		return nil
+	}
+
+	var aliases map[route.Vertex]string
+	if ctx.Bool("alias") {
+		aliases, err = lookupAliases(ctxb, client.Client, peers)
+		if err != nil {
+			return err
+		}
+	}
+	peer := func(node route.Vertex) string {
+		if alias := aliases[node]; alias != "" {
+			return truncate(alias, 10)
+		}
+
+		return hex.EncodeToString(node[:4])
+	}
+
+	sort.SliceStable(htlcs, func(i, j int) bool {
+		return htlcs[i].deadline(height) < htlcs[j].deadline(height)
+	})
+
+	fmt.Println()
+	table := newTextTable(
+		"Channel ID", "Peer", "Dir", "Amount", "Expiry", "Blocks Left",
+		"Force Close In", "",
+	)
+	table.alignLeft(1, 2, 7)
+
+	for i := range htlcs {
+		h := &htlcs[i]
+
+		direction := directionOut
+		if h.Incoming {
+			direction = directionIn
+		}
+
+		var flag string
+		if h.deadline(height) <= riskBlocks {
+			flag = "!"
+		}
+
+		table.addRow(
+			chanFmt.format(h.chanID),
+			peer(h.peer),
+			direction,
+			unit.format(sat(h.Amount)),
+			fmt.Sprint(h.Expiry),
+			fmt.Sprint(h.blocksLeft(height)),
+			fmt.Sprint(h.deadline(height)),
+			flag,
+		)
+	}
+	table.print()
+
+	sort.SliceStable(slots, func(i, j int) bool {
+		return slots[i].share() > slots[j].share()
+	})
+
+	fmt.Println()
+	table = newTextTable(
+		"Channel ID", "Peer", "In Slots", "Out Slots", "In Amount",
+		"Out Amount", "Slot Use",
+	)
+	table.alignLeft(1)
+
+	for _, s := range slots {
+		table.addRow(
+			chanFmt.format(s.chanID),
+			peer(s.peer),
+			fmt.Sprintf("%d/%d", s.in, s.maxIn),
+			fmt.Sprintf("%d/%d", s.out, s.maxOut),
+			unit.format(sat(s.amtIn)),
+			unit.format(sat(s.amtOut)),
+			fmt.Sprintf("%.1f%%", s.share()),
+		)
+	}
+	table.print()
+
+	return nil
+}
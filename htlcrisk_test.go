@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/lightninglabs/lndclient"
+)
+
+// TestHTLCDeadline checks the blocks left until an HTLC expires and until
+// lnd force closes its channel, 10 blocks earlier for incoming HTLCs by
+// lnd's defaults.
+func TestHTLCDeadline(t *testing.T) {
+	const height = 915000
+
+	tests := []struct {
+		name       string
+		incoming   bool
+		expiry     uint32
+		blocksLeft int64
+		deadline   int64
+	}{
+		{
+			name:       "incoming",
+			incoming:   true,
+			expiry:     915040,
+			blocksLeft: 40,
+			deadline:   30,
+		},
+		{
+			name:       "outgoing",
+			expiry:     915040,
+			blocksLeft: 40,
+			deadline:   40,
+		},
+		{
+			name:       "incoming within the broadcast delta",
+			incoming:   true,
+			expiry:     915004,
+			blocksLeft: 4,
+			deadline:   -6,
+		},
+		{
+			name:       "expired",
+			expiry:     914990,
+			blocksLeft: -10,
+			deadline:   -10,
+		},
+	}
+
+	for _, test := range tests {
+		h := &inFlightHTLC{
+			PendingHtlc: lndclient.PendingHtlc{
+				Incoming: test.incoming,
+				Expiry:   test.expiry,
+			},
+		}
+
+		if got := h.blocksLeft(height); got != test.blocksLeft {
+			t.Errorf("%s: %d blocks left, want %d", test.name, got,
+				test.blocksLeft)
+		}
+		if got := h.deadline(height); got != test.deadline {
+			t.Errorf("%s: force close in %d blocks, want %d",
+				test.name, got, test.deadline)
+		}
+	}
+}
@@ -0,0 +1,600 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/lnrpc/routerrpc"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	// htlcEventsFile is the store file watch htlcs records to.
+	htlcEventsFile = "htlcevents"
+
+	// The kinds of HTLC events lnb prints and records.
+	htlcForward     = "forward"
+	htlcSettle      = "settle"
+	htlcForwardFail = "forward_fail"
+	htlcLinkFail    = "link_fail"
+
+	// htlcTypeForward is the type of events of HTLCs we forwarded, as
+	// opposed to those we sent or received ourselves.
+	htlcTypeForward = "forward"
+
+	// maxHTLCHold is the longest an HTLC can be in flight, since lnd
+	// doesn't accept timelocks more than 2016 blocks out.
+	maxHTLCHold = 2016 * blockInterval
+
+	// htlcExpireInterval is how often the tracker looks for forwards
+	// held for longer than maxHTLCHold, in the time of the events.
+	htlcExpireInterval = time.Hour
+)
+
+// htlcEvent is an HTLC event of the router the way lnb records it. Settles
+// and forward failures carry the amounts and timelocks of the forward they
+// resolve if it was seen.
+type htlcEvent struct {
+	Time          time.Time           `json:"time"`
+	Kind          string              `json:"kind"`
+	Type          string              `json:"type"`
+	ChannelIn     uint64              `json:"chan_id_in,omitempty"`
+	ChannelOut    uint64              `json:"chan_id_out,omitempty"`
+	HtlcIn        uint64              `json:"htlc_id_in,omitempty"`
+	HtlcOut       uint64              `json:"htlc_id_out,omitempty"`
+	AmountIn      lnwire.MilliSatoshi `json:"amt_in_msat,omitempty"`
+	AmountOut     lnwire.MilliSatoshi `json:"amt_out_msat,omitempty"`
+	TimelockIn    uint32              `json:"timelock_in,omitempty"`
+	TimelockOut   uint32              `json:"timelock_out,omitempty"`
+	WireFailure   string              `json:"wire_failure,omitempty"`
+	FailureDetail string              `json:"failure_detail,omitempty"`
+	FailureString string              `json:"failure_string,omitempty"`
+}
+
+// newHTLCEvent converts an event of the router. Events which don't concern
+// a single HTLC are skipped.
+func newHTLCEvent(e *routerrpc.HtlcEvent) (*htlcEvent, bool) {
+	event := &htlcEvent{
+		Time:       time.Unix(0, int64(e.TimestampNs)).UTC(),
+		Type:       strings.ToLower(e.EventType.String()),
+		ChannelIn:  e.IncomingChannelId,
+		ChannelOut: e.OutgoingChannelId,
+		HtlcIn:     e.IncomingHtlcId,
+		HtlcOut:    e.OutgoingHtlcId,
+	}
+
+	var info *routerrpc.HtlcInfo
+	switch {
+	case e.GetForwardEvent() != nil:
+		event.Kind = htlcForward
+		info = e.GetForwardEvent().GetInfo()
+
+	case e.GetSettleEvent() != nil:
+		event.Kind = htlcSettle
+
+	case e.GetForwardFailEvent() != nil:
+		event.Kind = htlcForwardFail
+
+	case e.GetLinkFailEvent() != nil:
+		fail := e.GetLinkFailEvent()
+		event.Kind = htlcLinkFail
+		event.WireFailure = fail.WireFailure.String()
+		event.FailureDetail = fail.FailureDetail.String()
+		event.FailureString = fail.FailureString
+		info = fail.GetInfo()
+
+	default:
+		return nil, false
+	}
+
+	event.AmountIn = lnwire.MilliSatoshi(info.GetIncomingAmtMsat())
+	event.AmountOut = lnwire.MilliSatoshi(info.GetOutgoingAmtMsat())
+	event.TimelockIn = info.GetIncomingTimelock()
+	event.TimelockOut = info.GetOutgoingTimelock()
+
+	return event, true
+}
+
+// rpcEvent converts the event back to the one the router streamed.
+func (e *htlcEvent) rpcEvent() *routerrpc.HtlcEvent {
+	event := &routerrpc.HtlcEvent{
+		IncomingChannelId: e.ChannelIn,
+		OutgoingChannelId: e.ChannelOut,
+		IncomingHtlcId:    e.HtlcIn,
+		OutgoingHtlcId:    e.HtlcOut,
+		TimestampNs:       uint64(e.Time.UnixNano()),
+		EventType: routerrpc.HtlcEvent_EventType(
+			routerrpc.HtlcEvent_EventType_value[strings.ToUpper(e.Type)],
+		),
+	}
+
+	info := &routerrpc.HtlcInfo{
+		IncomingTimelock: e.TimelockIn,
+		OutgoingTimelock: e.TimelockOut,
+		IncomingAmtMsat:  uint64(e.AmountIn),
+		OutgoingAmtMsat:  uint64(e.AmountOut),
+	}
+
+	switch e.Kind {
+	case htlcForward:
+		event.Event = &routerrpc.HtlcEvent_ForwardEvent{
+			ForwardEvent: &routerrpc.ForwardEvent{Info: info},
+		}
+
+	case htlcSettle:
+		event.Event = &routerrpc.HtlcEvent_SettleEvent{
+			SettleEvent: &routerrpc.SettleEvent{},
+		}
+
+	case htlcForwardFail:
+		event.Event = &routerrpc.HtlcEvent_ForwardFailEvent{
+			ForwardFailEvent: &routerrpc.ForwardFailEvent{},
+		}
+
+	case htlcLinkFail:
+		event.Event = &routerrpc.HtlcEvent_LinkFailEvent{
+			LinkFailEvent: &routerrpc.LinkFailEvent{
+				Info: info,
+				WireFailure: lnrpc.Failure_FailureCode(
+					lnrpc.Failure_FailureCode_value[e.WireFailure],
+				),
+				FailureDetail: routerrpc.FailureDetail(
+					routerrpc.FailureDetail_value[e.FailureDetail],
+				),
+				FailureString: e.FailureString,
+			},
+		}
+	}
+
+	return event
+}
+
+// failed returns whether the event is a failure.
+func (e *htlcEvent) failed() bool {
+	return e.Kind == htlcForwardFail || e.Kind == htlcLinkFail
+}
+
+// amount returns the amount of the HTLC, what went out unless it was
+// received by us.
+func (e *htlcEvent) amount() lnwire.MilliSatoshi {
+	if e.AmountOut > 0 {
+		return e.AmountOut
+	}
+
+	return e.AmountIn
+}
+
+// fee returns the fee a forward pays, zero if the amounts aren't known.
+func (e *htlcEvent) fee() lnwire.MilliSatoshi {
+	if e.AmountOut == 0 || e.AmountIn < e.AmountOut {
+		return 0
+	}
+
+	return e.AmountIn - e.AmountOut
+}
+
+// reason returns why the HTLC failed. Failures downstream don't tell, link
+// failures are described by their detail unless lnd only has the wire
+// failure to offer.
+func (e *htlcEvent) reason() string {
+	humanize := func(s string) string {
+		return strings.ToLower(strings.ReplaceAll(s, "_", " "))
+	}
+
+	switch {
+	case e.Kind == htlcForwardFail:
+		return "failed downstream"
+
+	case e.Kind != htlcLinkFail:
+		return ""
+
+	case e.FailureDetail != "" && e.FailureDetail != "UNKNOWN" &&
+		e.FailureDetail != "NO_DETAIL":
+
+		return humanize(e.FailureDetail)
+
+	case e.WireFailure != "" && e.WireFailure != "RESERVED":
+		return humanize(e.WireFailure)
+
+	case e.FailureString != "":
+		return e.FailureString
+
+	default:
+		return "unknown"
+	}
+}
+
+// htlcKey identifies an HTLC by the circuit it is forwarded on.
+type htlcKey struct {
+	chanIn, htlcIn   uint64
+	chanOut, htlcOut uint64
+}
+
+// htlcTracker pairs the HTLCs in flight with the events resolving them.
+type htlcTracker struct {
+	inFlight map[htlcKey]htlcEvent
+
+	// expired is the time of the event the forwards were last expired
+	// at.
+	expired time.Time
+}
+
+// newHTLCTracker returns a tracker without HTLCs in flight.
+func newHTLCTracker() *htlcTracker {
+	return &htlcTracker{inFlight: make(map[htlcKey]htlcEvent)}
+}
+
+// track adds a forward to the HTLCs in flight, or resolves the one a settle
+// or failure concerns, copying its amounts and timelocks to the event. The
+// forward resolved is returned, false if it wasn't seen.
+func (t *htlcTracker) track(e *htlcEvent) (htlcEvent, bool) {
+	t.expire(e.Time)
+
+	key := htlcKey{e.ChannelIn, e.HtlcIn, e.ChannelOut, e.HtlcOut}
+
+	switch e.Kind {
+	case htlcForward:
+		t.inFlight[key] = *e
+		return htlcEvent{}, false
+
+	case htlcSettle, htlcForwardFail:
+		forward, ok := t.inFlight[key]
+		if !ok {
+			return htlcEvent{}, false
+		}
+		delete(t.inFlight, key)
+
+		e.AmountIn, e.AmountOut = forward.AmountIn, forward.AmountOut
+		e.TimelockIn, e.TimelockOut = forward.TimelockIn,
+			forward.TimelockOut
+
+		return forward, true
+	}
+
+	return htlcEvent{}, false
+}
+
+// expire drops the forwards in flight for longer than any HTLC can be at
+// the time of an event. Their resolution was missed, e.g. while lnd or lnb
+// restarted, and would be kept forever otherwise.
+func (t *htlcTracker) expire(now time.Time) {
+	if now.Sub(t.expired) < htlcExpireInterval {
+		return
+	}
+	t.expired = now
+
+	for key, forward := range t.inFlight {
+		if now.Sub(forward.Time) > maxHTLCHold {
+			delete(t.inFlight, key)
+		}
+	}
+}
+
+// watchHTLCs prints the HTLC events of the router as they happen and records
+// them in the store until interrupted.
+func watchHTLCs(ctx *cli.Context) error {
+	ctxb := ctx.Context
+	client, err := getClient(ctxb, ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to LND: %w", err)
+	}
+	defer client.Close()
+
+	chanFmt, err := getChanFormat(ctx)
+	if err != nil {
+		return err
+	}
+
+	unit, err := getAmountUnit(ctx)
+	if err != nil {
+		return err
+	}
+
+	loc, err := time.LoadLocation(ctx.String("tz"))
+	if err != nil {
+		return fmt.Errorf("invalid --tz: %w", err)
+	}
+
+	// Replaying a recording would add its events to the history of the
+	// node a second time.
+	var store *nodeStore
+	if !ctx.Bool("no-record") && !fixtureBackend(ctx) {
+		store, err = connectedStore(ctxb, ctx, client.Client)
+		if err != nil {
+			return err
+		}
+	}
+
+	events, errs, err := client.Client.SubscribeHtlcEvents(ctxb)
+	if err != nil {
+		return fmt.Errorf("unable to subscribe to HTLC events: %w", err)
+	}
+
+	channel := func(id uint64) string {
+		if id == 0 {
+			return "-"
+		}
+
+		return strings.TrimSpace(chanFmt.format(id))
+	}
+
+	// Settles and failures of HTLCs which weren't seen being forwarded
+	// don't know their amounts.
+	amount := func(msat lnwire.MilliSatoshi) string {
+		if msat == 0 {
+			return "-"
+		}
+
+		return unit.format(msat)
+	}
+
+	const lineFormat = "%-19s  %-12s  %-7s  %-20s  %-20s  %14s  %10s  %s\n"
+	fmt.Printf(lineFormat, "Time", "Event", "Type", "In", "Out", "Amount",
+		"Fee", "Reason")
+
+	tracker := newHTLCTracker()
+	for {
+		select {
+		case rpcEvent, ok := <-events:
+			if !ok {
+				return nil
+			}
+
+			e, ok := newHTLCEvent(rpcEvent)
+			if !ok {
+				continue
+			}
+			tracker.track(e)
+
+			fmt.Printf(lineFormat,
+				e.Time.In(loc).Format(time.DateTime), e.Kind,
+				e.Type, channel(e.ChannelIn),
+				channel(e.ChannelOut), amount(e.amount()),
+				amount(e.fee()), e.reason())
+
+			if store != nil {
+				if err := store.append(htlcEventsFile, e); err != nil {
+					return err
+				}
+			}
+
+		case err := <-errs:
+			if ctxb.Err() != nil {
+				return nil
+			}
+
+			return fmt.Errorf("HTLC event stream failed: %w", err)
+
+		case <-ctxb.Done():
+			return nil
+		}
+	}
+}
+
+// channelFailures sums up the forwards which failed on their way out of a
+// channel.
+type channelFailures struct {
+	chanID   uint64
+	failures int
+	settled  int
+	amount   lnwire.MilliSatoshi
+	reasons  map[string]int
+}
+
+// rate returns the share of the resolved forwards which failed in percent.
+func (c *channelFailures) rate() float64 {
+	return float64(c.failures) / float64(c.failures+c.settled) * 100
+}
+
+// topReason returns the most frequent reason of the failures.
+func (c *channelFailures) topReason() string {
+	var (
+		top   string
+		count int
+	)
+	for reason, n := range c.reasons {
+		if n > count || n == count && reason < top {
+			top, count = reason, n
+		}
+	}
+
+	return top
+}
+
+// reasonFailures sums up the failures of one reason.
+type reasonFailures struct {
+	reason   string
+	failures int
+	amount   lnwire.MilliSatoshi
+}
+
+// reportFailures prints which channels forwards fail on most, and why, from
+// the HTLC events watch htlcs recorded.
+func reportFailures(ctx *cli.Context) error {
+	ctxb := ctx.Context
+	client, err := getClient(ctxb, ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to LND: %w", err)
+	}
+	defer client.Close()
+
+	chanFmt, err := getChanFormat(ctx)
+	if err != nil {
+		return err
+	}
+
+	unit, err := getAmountUnit(ctx)
+	if err != nil {
+		return err
+	}
+
+	loc, err := time.LoadLocation(ctx.String("tz"))
+	if err != nil {
+		return fmt.Errorf("invalid --tz: %w", err)
+	}
+	now := client.Now()
+
+	start, err := parseTimeSpec(ctx.String("since"), now, loc)
+	if err != nil {
+		return fmt.Errorf("invalid --since: %w", err)
+	}
+
+	end := now
+	if ctx.IsSet("until") {
+		end, err = parseTimeSpec(ctx.String("until"), now, loc)
+		if err != nil {
+			return fmt.Errorf("invalid --until: %w", err)
+		}
+	}
+
+	store, err := connectedStore(ctxb, ctx, client.Client)
+	if err != nil {
+		return err
+	}
+
+	events, err := readRecords[htlcEvent](store, htlcEventsFile)
+	if err != nil {
+		return err
+	}
+
+	var (
+		channels = make(map[uint64]*channelFailures)
+		reasons  = make(map[string]*reasonFailures)
+		count    int
+	)
+	for _, e := range events {
+		if e.Type != htlcTypeForward || e.Time.Before(start) ||
+			e.Time.After(end) {
+
+			continue
+		}
+
+		if e.Kind != htlcSettle && !e.failed() {
+			continue
+		}
+		count++
+
+		c, ok := channels[e.ChannelOut]
+		if !ok {
+			c = &channelFailures{
+				chanID:  e.ChannelOut,
+				reasons: make(map[string]int),
+			}
+			channels[e.ChannelOut] = c
+		}
+
+		if e.Kind == htlcSettle {
+			c.settled++
+			continue
+		}
+
+		reason := e.reason()
+		c.failures++
+		c.amount += e.amount()
+		c.reasons[reason]++
+
+		r, ok := reasons[reason]
+		if !ok {
+			r = &reasonFailures{reason: reason}
+			reasons[reason] = r
+		}
+		r.failures++
+		r.amount += e.amount()
+	}
+
+	fmt.Printf("%d resolved forwards from %s to %s, recorded in %s\n\n",
+		count, start.In(loc).Format(time.DateOnly),
+		end.In(loc).Format(time.DateOnly), store.path(htlcEventsFile))
+	if count == 0 {
+		fmt.Println("Run lnb watch htlcs to record HTLC events.")
+		return nil
+	}
+
+	f := &rowFormat{chanFmt: chanFmt, unit: unit}
+	printChannelFailures(channels, f)
+	fmt.Println()
+	printReasonFailures(reasons, unit)
+
+	return nil
+}
+
+// printChannelFailures prints the failures per outgoing channel, those with
+// the most failures first. Channels without failures are left out.
+func printChannelFailures(channels map[uint64]*channelFailures, f *rowFormat) {
+	sorted := make([]*channelFailures, 0, len(channels))
+	for _, c := range channels {
+		if c.failures > 0 {
+			sorted = append(sorted, c)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].failures != sorted[j].failures {
+			return sorted[i].failures > sorted[j].failures
+		}
+
+		return sorted[i].chanID < sorted[j].chanID
+	})
+
+	table := newTextTable(
+		"Channel ID", "Failures", "Failed Amount", "Settled", "Fail Rate",
+		"Top Reason",
+	)
+	table.alignLeft(5)
+
+	for _, c := range sorted {
+		// Link failures for an unknown next peer have no channel.
+		chanID := "unknown"
+		if c.chanID != 0 {
+			chanID = f.chanFmt.format(c.chanID)
+		}
+
+		table.addRow(
+			chanID,
+			fmt.Sprint(c.failures),
+			f.unit.format(c.amount),
+			fmt.Sprint(c.settled),
+			fmt.Sprintf("%.1f%%", c.rate()),
+			c.topReason(),
+		)
+	}
+
+	table.print()
+}
+
+// printReasonFailures prints how often each reason failed a forward.
+func printReasonFailures(reasons map[string]*reasonFailures, unit amountUnit) {
+	var (
+		sorted = make([]*reasonFailures, 0, len(reasons))
+		total  int
+	)
+	for _, r := range reasons {
+		sorted = append(sorted, r)
+		total += r.failures
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].failures != sorted[j].failures {
+			return sorted[i].failures > sorted[j].failures
+		}
+
+		return sorted[i].reason < sorted[j].reason
+	})
+
+	table := newTextTable("Reason", "Failures", "Amount", "Share")
+	table.alignLeft(0)
+
+	for _, r := range sorted {
+		table.addRow(
+			r.reason,
+			fmt.Sprint(r.failures),
+			unit.format(r.amount),
+			fmt.Sprintf("%.1f%%",
+				float64(r.failures)/float64(total)*100),
+		)
+	}
+
+	table.print()
+}
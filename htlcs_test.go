@@ -0,0 +1,276 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/lnrpc/routerrpc"
+)
+
+// testForward returns a forward from channel 1 to channel 2 at the time.
+func testForward(at time.Time, htlcIn, htlcOut uint64) *htlcEvent {
+	return &htlcEvent{
+		Time:        at,
+		Kind:        htlcForward,
+		Type:        htlcTypeForward,
+		ChannelIn:   1,
+		ChannelOut:  2,
+		HtlcIn:      htlcIn,
+		HtlcOut:     htlcOut,
+		AmountIn:    1_001_000,
+		AmountOut:   1_000_000,
+		TimelockIn:  800_140,
+		TimelockOut: 800_100,
+	}
+}
+
+// testResolution returns the event of the kind resolving the forward.
+func testResolution(at time.Time, kind string, htlcIn,
+	htlcOut uint64) *htlcEvent {
+
+	return &htlcEvent{
+		Time:       at,
+		Kind:       kind,
+		Type:       htlcTypeForward,
+		ChannelIn:  1,
+		ChannelOut: 2,
+		HtlcIn:     htlcIn,
+		HtlcOut:    htlcOut,
+	}
+}
+
+// TestHTLCTrackerPairs checks that settles and downstream failures are
+// paired with their forward and get its amounts and timelocks.
+func TestHTLCTrackerPairs(t *testing.T) {
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, kind := range []string{htlcSettle, htlcForwardFail} {
+		t.Run(kind, func(t *testing.T) {
+			tracker := newHTLCTracker()
+
+			forward := testForward(start, 7, 9)
+			if _, ok := tracker.track(forward); ok {
+				t.Fatal("a forward resolved another one")
+			}
+
+			// A different HTLC on the same channels isn't
+			// paired.
+			other := testResolution(
+				start.Add(time.Second), kind, 7, 10,
+			)
+			if _, ok := tracker.track(other); ok {
+				t.Fatal("resolved a forward of another HTLC")
+			}
+			if other.AmountIn != 0 || other.TimelockOut != 0 {
+				t.Fatalf("unpaired %s got amounts or timelocks: "+
+					"%+v", kind, other)
+			}
+
+			e := testResolution(start.Add(time.Minute), kind, 7, 9)
+			got, ok := tracker.track(e)
+			if !ok {
+				t.Fatalf("%s didn't resolve the forward", kind)
+			}
+			if !reflect.DeepEqual(got, *forward) {
+				t.Fatalf("resolved %+v, want %+v", got, *forward)
+			}
+
+			if e.AmountIn != forward.AmountIn ||
+				e.AmountOut != forward.AmountOut ||
+				e.TimelockIn != forward.TimelockIn ||
+				e.TimelockOut != forward.TimelockOut {
+
+				t.Fatalf("%s got amounts and timelocks %+v, "+
+					"want those of %+v", kind, e, forward)
+			}
+			if e.fee() != 1_000 {
+				t.Fatalf("%s pays a fee of %v, want 1000 msat",
+					kind, e.fee())
+			}
+
+			// The forward is resolved only once.
+			again := testResolution(
+				start.Add(2*time.Minute), kind, 7, 9,
+			)
+			if _, ok := tracker.track(again); ok {
+				t.Fatal("the forward was resolved twice")
+			}
+			if len(tracker.inFlight) != 0 {
+				t.Fatalf("%d forwards still in flight",
+					len(tracker.inFlight))
+			}
+		})
+	}
+}
+
+// TestHTLCTrackerExpire checks that forwards held for longer than any HTLC
+// can be are dropped, and younger ones kept.
+func TestHTLCTrackerExpire(t *testing.T) {
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	tracker := newHTLCTracker()
+	tracker.track(testForward(start, 1, 1))
+	tracker.track(testForward(start.Add(maxHTLCHold/2), 2, 2))
+
+	// Another forward once the first one is past maxHTLCHold.
+	later := start.Add(maxHTLCHold + htlcExpireInterval)
+	tracker.track(testForward(later, 3, 3))
+
+	if len(tracker.inFlight) != 2 {
+		t.Fatalf("%d forwards in flight, want 2", len(tracker.inFlight))
+	}
+	if _, ok := tracker.inFlight[htlcKey{1, 1, 2, 1}]; ok {
+		t.Fatal("the forward older than maxHTLCHold wasn't dropped")
+	}
+
+	// The settle of a dropped forward isn't paired any more.
+	settle := testResolution(later.Add(time.Minute), htlcSettle, 1, 1)
+	if _, ok := tracker.track(settle); ok {
+		t.Fatal("settled a forward which was expired")
+	}
+
+	settle = testResolution(later.Add(time.Minute), htlcSettle, 2, 2)
+	if _, ok := tracker.track(settle); !ok {
+		t.Fatal("the forward younger than maxHTLCHold was dropped")
+	}
+}
+
+// TestHTLCEventRoundTrip checks that recorded events convert back to the
+// events of the router they were converted from.
+func TestHTLCEventRoundTrip(t *testing.T) {
+	at := time.Date(2026, 10, 1, 12, 0, 0, 123456789, time.UTC)
+
+	linkFail := testResolution(at, htlcLinkFail, 3, 0)
+	linkFail.AmountIn, linkFail.AmountOut = 2_002_000, 2_000_000
+	linkFail.TimelockIn, linkFail.TimelockOut = 800_200, 800_160
+	linkFail.WireFailure = "TEMPORARY_CHANNEL_FAILURE"
+	linkFail.FailureDetail = "INSUFFICIENT_BALANCE"
+	linkFail.FailureString = "insufficient bandwidth to route htlc"
+
+	send := &htlcEvent{
+		Time:       at,
+		Kind:       htlcForward,
+		Type:       "send",
+		ChannelOut: 2,
+		HtlcOut:    4,
+		AmountOut:  5_000_000,
+	}
+
+	// Settles and downstream failures carry no amounts on the wire.
+	tests := []*htlcEvent{
+		testForward(at, 1, 2),
+		testResolution(at, htlcSettle, 1, 2),
+		testResolution(at, htlcForwardFail, 1, 2),
+		linkFail,
+		send,
+	}
+
+	for _, want := range tests {
+		got, ok := newHTLCEvent(want.rpcEvent())
+		if !ok {
+			t.Errorf("%s event was skipped", want.Kind)
+			continue
+		}
+
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s event came back as\n%+v\nwant\n%+v",
+				want.Kind, got, want)
+		}
+	}
+
+	// Events which aren't about a single HTLC are skipped.
+	if _, ok := newHTLCEvent(&routerrpc.HtlcEvent{}); ok {
+		t.Error("an event without details wasn't skipped")
+	}
+}
+
+// TestHTLCEventReason checks the reasons link failures are described by.
+func TestHTLCEventReason(t *testing.T) {
+	linkFail := func(wire lnrpc.Failure_FailureCode,
+		detail routerrpc.FailureDetail, msg string) *htlcEvent {
+
+		e, ok := newHTLCEvent(&routerrpc.HtlcEvent{
+			EventType: routerrpc.HtlcEvent_FORWARD,
+			Event: &routerrpc.HtlcEvent_LinkFailEvent{
+				LinkFailEvent: &routerrpc.LinkFailEvent{
+					Info:          &routerrpc.HtlcInfo{},
+					WireFailure:   wire,
+					FailureDetail: detail,
+					FailureString: msg,
+				},
+			},
+		})
+		if !ok {
+			t.Fatal("link failure was skipped")
+		}
+
+		return e
+	}
+
+	tests := []struct {
+		name  string
+		event *htlcEvent
+		want  string
+	}{
+		{
+			name: "fee insufficient",
+			event: linkFail(
+				lnrpc.Failure_FEE_INSUFFICIENT,
+				routerrpc.FailureDetail_NO_DETAIL, "",
+			),
+			want: "fee insufficient",
+		},
+		{
+			name: "incorrect cltv expiry",
+			event: linkFail(
+				lnrpc.Failure_INCORRECT_CLTV_EXPIRY,
+				routerrpc.FailureDetail_NO_DETAIL, "",
+			),
+			want: "incorrect cltv expiry",
+		},
+		{
+			name: "insufficient balance",
+			event: linkFail(
+				lnrpc.Failure_TEMPORARY_CHANNEL_FAILURE,
+				routerrpc.FailureDetail_INSUFFICIENT_BALANCE,
+				"insufficient bandwidth to route htlc",
+			),
+			want: "insufficient balance",
+		},
+		{
+			name: "failure string only",
+			event: linkFail(
+				lnrpc.Failure_RESERVED,
+				routerrpc.FailureDetail_UNKNOWN, "custom",
+			),
+			want: "custom",
+		},
+		{
+			name: "nothing known",
+			event: linkFail(
+				lnrpc.Failure_RESERVED,
+				routerrpc.FailureDetail_UNKNOWN, "",
+			),
+			want: "unknown",
+		},
+		{
+			name:  "downstream",
+			event: testResolution(time.Time{}, htlcForwardFail, 1, 2),
+			want:  "failed downstream",
+		},
+		{
+			name:  "settle",
+			event: testResolution(time.Time{}, htlcSettle, 1, 2),
+			want:  "",
+		},
+	}
+
+	for _, test := range tests {
+		if got := test.event.reason(); got != test.want {
+			t.Errorf("%s: reason %q, want %q", test.name, got,
+				test.want)
+		}
+	}
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	// Directions of forwards relative to a channel.
+	directionIn  = "in"
+	directionOut = "out"
+
+	// histogramWidth is the length of the longest bar of a histogram.
+	histogramWidth = 40
+)
+
+// sizeSample are the amounts of the forwards through a channel in one
+// direction, with the fees they earned.
+type sizeSample struct {
+	chanID    uint64
+	direction string
+
+	amounts []lnwire.MilliSatoshi
+	volume  lnwire.MilliSatoshi
+	fee     lnwire.MilliSatoshi
+}
+
+// add records a forward of the given amount which earned fee.
+func (s *sizeSample) add(amount, fee lnwire.MilliSatoshi) {
+	s.amounts = append(s.amounts, amount)
+	s.volume += amount
+	s.fee += fee
+}
+
+// percentile returns the amount at the given percentile by the nearest-rank
+// method. The amounts must be sorted.
+func (s *sizeSample) percentile(p float64) lnwire.MilliSatoshi {
+	rank := int(math.Ceil(p / 100 * float64(len(s.amounts))))
+	return s.amounts[min(max(rank, 1), len(s.amounts))-1]
+}
+
+// sampleSizes groups the amounts of the forwards by channel and direction.
+// Incoming forwards are sized by the amount which came in, outgoing ones by
+// the amount which went out. The channels are sorted by count, busiest
+// first.
+func sampleSizes(events []lndclient.ForwardingEvent,
+	channels map[uint64]bool) []*sizeSample {
+
+	type key struct {
+		chanID    uint64
+		direction string
+	}
+	samples := make(map[key]*sizeSample)
+	sample := func(chanID uint64, direction string) *sizeSample {
+		k := key{chanID, direction}
+		s, ok := samples[k]
+		if !ok {
+			s = &sizeSample{chanID: chanID, direction: direction}
+			samples[k] = s
+		}
+
+		return s
+	}
+
+	for _, e := range events {
+		if len(channels) == 0 || channels[e.ChannelIn] {
+			sample(e.ChannelIn, directionIn).add(e.AmountMsatIn, e.FeeMsat)
+		}
+		if len(channels) == 0 || channels[e.ChannelOut] {
+			sample(e.ChannelOut, directionOut).add(
+				e.AmountMsatOut, e.FeeMsat,
+			)
+		}
+	}
+
+	sorted := make([]*sizeSample, 0, len(samples))
+	for _, s := range samples {
+		sort.Slice(s.amounts, func(i, j int) bool {
+			return s.amounts[i] < s.amounts[j]
+		})
+		sorted = append(sorted, s)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if len(sorted[i].amounts) != len(sorted[j].amounts) {
+			return len(sorted[i].amounts) > len(sorted[j].amounts)
+		}
+		if sorted[i].chanID != sorted[j].chanID {
+			return sorted[i].chanID > sorted[j].chanID
+		}
+
+		return sorted[i].direction < sorted[j].direction
+	})
+
+	return sorted
+}
+
+// reportHTLCSizes prints the distribution of the forwarded amounts per
+// channel and direction.
+func reportHTLCSizes(ctx *cli.Context) error {
+	ctxb := ctx.Context
+	client, err := getClient(ctxb, ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to LND: %w", err)
+	}
+	defer client.Close()
+
+	chanFmt, err := getChanFormat(ctx)
+	if err != nil {
+		return err
+	}
+
+	unit, err := getAmountUnit(ctx)
+	if err != nil {
+		return err
+	}
+
+	loc, err := time.LoadLocation(ctx.String("tz"))
+	if err != nil {
+		return fmt.Errorf("invalid --tz: %w", err)
+	}
+	now := client.Now()
+
+	start, err := parseTimeSpec(ctx.String("since"), now, loc)
+	if err != nil {
+		return fmt.Errorf("invalid --since: %w", err)
+	}
+
+	end := now
+	if ctx.IsSet("until") {
+		end, err = parseTimeSpec(ctx.String("until"), now, loc)
+		if err != nil {
+			return fmt.Errorf("invalid --until: %w", err)
+		}
+	}
+
+	channels := make(map[uint64]bool)
+	ids, err := resolveChanIDs(ctxb, client.Client, ctx.StringSlice("channel"))
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		channels[id] = true
+	}
+
+	events, err := fetchForwards(ctxb, client.Client, start, end)
+	if err != nil {
+		return err
+	}
+
+	f := &rowFormat{chanFmt: chanFmt, unit: unit}
+
+	if ctx.Bool("histogram") {
+		// Without a channel, every forward is counted once by what
+		// it sent out.
+		if len(channels) == 0 {
+			all := &sizeSample{direction: directionOut}
+			for _, e := range events {
+				all.add(e.AmountMsatOut, e.FeeMsat)
+			}
+			sort.Slice(all.amounts, func(i, j int) bool {
+				return all.amounts[i] < all.amounts[j]
+			})
+			printHistogram("All forwards", all, f)
+
+			return nil
+		}
+
+		for i, s := range sampleSizes(events, channels) {
+			if i > 0 {
+				fmt.Println()
+			}
+
+			title := fmt.Sprintf("Channel %s %s",
+				strings.TrimSpace(chanFmt.format(s.chanID)),
+				s.direction)
+			printHistogram(title, s, f)
+		}
+
+		return nil
+	}
+
+	printSizes(sampleSizes(events, channels), f)
+
+	return nil
+}
+
+// printSizes prints the percentiles of the amounts of each sample.
+func printSizes(samples []*sizeSample, f *rowFormat) {
+	table := newTextTable(
+		"Channel ID", "Dir", "Count", "Min", "P50", "P90", "P99", "Max",
+		"Volume", "Fees", "PPM",
+	)
+	table.alignLeft(1)
+
+	for _, s := range samples {
+		table.addRow(
+			f.chanFmt.format(s.chanID),
+			s.direction,
+			fmt.Sprint(len(s.amounts)),
+			f.unit.format(s.amounts[0]),
+			f.unit.format(s.percentile(50)),
+			f.unit.format(s.percentile(90)),
+			f.unit.format(s.percentile(99)),
+			f.unit.format(s.amounts[len(s.amounts)-1]),
+			f.unit.format(s.volume),
+			f.unit.format(s.fee),
+			fmt.Sprint(ppm(s.fee, s.volume)),
+		)
+	}
+
+	table.print()
+}
+
+// histogramEdges returns the edges of the buckets of a histogram covering
+// the amounts from lo to hi: 1, 2 and 5 times the powers of ten, in
+// satoshis. The first bucket starts at lo if it is below one satoshi, the
+// last edge is above hi.
+func histogramEdges(lo, hi lnwire.MilliSatoshi) []lnwire.MilliSatoshi {
+	edges := []lnwire.MilliSatoshi{min(lo, 1000)}
+	for decade := lnwire.MilliSatoshi(1000); ; decade *= 10 {
+		for _, step := range []lnwire.MilliSatoshi{1, 2, 5} {
+			edge := decade * step
+			switch {
+			case edge > hi:
+				return append(edges, edge)
+
+			// The last edge at or below lo starts the first
+			// bucket.
+			case edge <= lo:
+				edges[0] = edge
+
+			case edge > edges[0]:
+				edges = append(edges, edge)
+			}
+		}
+	}
+}
+
+// printHistogram prints the amounts of a sample as a histogram of
+// logarithmic buckets. The amounts must be sorted.
+func printHistogram(title string, s *sizeSample, f *rowFormat) {
+	fmt.Printf("%s, %d forwards\n", title, len(s.amounts))
+	if len(s.amounts) == 0 {
+		return
+	}
+
+	edges := histogramEdges(s.amounts[0], s.amounts[len(s.amounts)-1])
+
+	counts := make([]int, len(edges)-1)
+	for _, a := range s.amounts {
+		i := sort.Search(len(edges), func(i int) bool {
+			return edges[i] > a
+		})
+		counts[i-1]++
+	}
+
+	largest := 0
+	for _, c := range counts {
+		largest = max(largest, c)
+	}
+
+	table := newTextTable("From", "To", "Count", "Share", "")
+	table.alignLeft(4)
+	for i, c := range counts {
+		bar := strings.Repeat("█", int(math.Round(
+			float64(c)/float64(largest)*histogramWidth,
+		)))
+		if c > 0 && bar == "" {
+			bar = "▏"
+		}
+
+		table.addRow(
+			f.unit.format(edges[i]),
+			f.unit.format(edges[i+1]),
+			fmt.Sprint(c),
+			fmt.Sprintf("%.1f%%",
+				float64(c)/float64(len(s.amounts))*100),
+			bar,
+		)
+	}
+
+	table.print()
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/lightningnetwork/lnd/lnwire"
+)
+
+// TestPercentile checks the nearest-rank percentiles of a sample.
+func TestPercentile(t *testing.T) {
+	ten := &sizeSample{}
+	for i := 1; i <= 10; i++ {
+		ten.add(lnwire.MilliSatoshi(i*1000), 0)
+	}
+	one := &sizeSample{amounts: []lnwire.MilliSatoshi{42_000}}
+
+	tests := []struct {
+		name   string
+		sample *sizeSample
+		p      float64
+		want   lnwire.MilliSatoshi
+	}{
+		{"p0 is the minimum", ten, 0, 1000},
+		{"p10", ten, 10, 1000},
+		{"p11 rounds up", ten, 11, 2000},
+		{"p50", ten, 50, 5000},
+		{"p90", ten, 90, 9000},
+		{"p99", ten, 99, 10000},
+		{"p100 is the maximum", ten, 100, 10000},
+		{"single p1", one, 1, 42_000},
+		{"single p50", one, 50, 42_000},
+		{"single p99", one, 99, 42_000},
+	}
+
+	for _, test := range tests {
+		if got := test.sample.percentile(test.p); got != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+
+	if ten.volume != 55_000 {
+		t.Errorf("volume %v, want 55000 msat", ten.volume)
+	}
+}
+
+// TestHistogramEdges checks the 1-2-5 buckets covering the amounts, with
+// the first edge at or below the smallest amount and the last above the
+// largest.
+func TestHistogramEdges(t *testing.T) {
+	tests := []struct {
+		name   string
+		lo, hi lnwire.MilliSatoshi
+		want   []lnwire.MilliSatoshi
+	}{
+		{
+			name: "zero",
+			lo:   0,
+			hi:   0,
+			want: []lnwire.MilliSatoshi{0, 1000},
+		},
+		{
+			name: "below a satoshi",
+			lo:   500,
+			hi:   1500,
+			want: []lnwire.MilliSatoshi{500, 1000, 2000},
+		},
+		{
+			name: "a single amount on an edge",
+			lo:   2000,
+			hi:   2000,
+			want: []lnwire.MilliSatoshi{2000, 5000},
+		},
+		{
+			name: "largest amount on an edge",
+			lo:   1500,
+			hi:   5000,
+			want: []lnwire.MilliSatoshi{1000, 2000, 5000, 10000},
+		},
+		{
+			name: "decades",
+			lo:   64_000,
+			hi:   757_008,
+			want: []lnwire.MilliSatoshi{
+				50_000, 100_000, 200_000, 500_000, 1_000_000,
+			},
+		},
+		{
+			name: "up to a bitcoin",
+			lo:   499_999_999,
+			hi:   100_000_000_000,
+			want: []lnwire.MilliSatoshi{
+				200_000_000, 500_000_000, 1_000_000_000,
+				2_000_000_000, 5_000_000_000, 10_000_000_000,
+				20_000_000_000, 50_000_000_000,
+				100_000_000_000, 200_000_000_000,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		got := histogramEdges(test.lo, test.hi)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+			continue
+		}
+
+		if got[0] > test.lo || got[len(got)-1] <= test.hi {
+			t.Errorf("%s: %v doesn't cover %v to %v", test.name, got,
+				test.lo, test.hi)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/routing/route"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// anchorCommitWeight is the weight of an anchor commitment
+	// transaction without HTLCs, the channel type lnd opens by default.
+	anchorCommitWeight = 1124
+
+	// coopCloseWeight is the weight of a cooperative close transaction
+	// spending the funding output to two taproot or P2WSH outputs.
+	coopCloseWeight = 772
+)
+
+// idleChannel is an open channel which didn't forward anything for a while.
+type idleChannel struct {
+	chanID uint64
+	peer   route.Vertex
+	alias  string
+	active bool
+
+	age      time.Duration
+	capacity btcutil.Amount
+	local    btcutil.Amount
+
+	// uptime is the share of the channel's lifetime lnd saw the peer
+	// online, negative if it isn't known.
+	uptime float64
+
+	// closeFee is the estimated fee we pay to close the channel
+	// cooperatively, zero if the peer opened it and pays it.
+	closeFee btcutil.Amount
+}
+
+// freed returns what closing the channel gives back to our wallet.
+func (c *idleChannel) freed() btcutil.Amount {
+	return c.local - c.closeFee
+}
+
+// closeFeeEstimate estimates the fee of closing a channel cooperatively. If
+// satPerVByte is zero, the fee rate is taken from the commitment
+// transaction, whose fee lnd keeps up to date with the fee estimates.
+func closeFeeEstimate(c lndclient.ChannelInfo,
+	satPerVByte float64) btcutil.Amount {
+
+	if satPerVByte > 0 {
+		return btcutil.Amount(math.Ceil(
+			satPerVByte * coopCloseWeight / 4,
+		))
+	}
+
+	return c.CommitFee * coopCloseWeight / anchorCommitWeight
+}
+
+// reportIdle prints the open channels which had no forwards in either
+// direction for --days, the best ones to close first.
+func reportIdle(ctx *cli.Context) error {
+	ctxb := ctx.Context
+	client, err := getClient(ctxb, ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to LND: %w", err)
+	}
+	defer client.Close()
+
+	chanFmt, err := getChanFormat(ctx)
+	if err != nil {
+		return err
+	}
+
+	unit, err := getAmountUnit(ctx)
+	if err != nil {
+		return err
+	}
+
+	days := ctx.Int("days")
+	if days <= 0 {
+		return fmt.Errorf("invalid --days %d", days)
+	}
+
+	satPerVByte := ctx.Float64("sat-per-vbyte")
+	if satPerVByte < 0 {
+		return fmt.Errorf("invalid --sat-per-vbyte %v", satPerVByte)
+	}
+
+	now := client.Now()
+	window := time.Duration(days) * 24 * time.Hour
+	start := now.Add(-window)
+
+	var (
+		info     *lndclient.Info
+		channels []lndclient.ChannelInfo
+		events   []lndclient.ForwardingEvent
+	)
+	g, gctx := errgroup.WithContext(ctxb)
+	g.Go(func() error {
+		var err error
+		info, err = client.Client.GetInfo(gctx)
+		return err
+	})
+	g.Go(func() error {
+		var err error
+		channels, err = client.Client.ListChannels(gctx, false, false)
+		return err
+	})
+	g.Go(func() error {
+		var err error
+		events, err = fetchForwards(gctx, client.Client, start, now)
+		return err
+	})
+	if err := g.Wait(); err != nil {
+		return err
+	}
+
+	forwarded := make(map[uint64]bool)
+	for _, e := range events {
+		forwarded[e.ChannelIn] = true
+		forwarded[e.ChannelOut] = true
+	}
+
+	var idle []idleChannel
+	for _, c := range channels {
+		if forwarded[c.ChannelID] {
+			continue
+		}
+
+		// Channels younger than the window had no fair chance to
+		// forward yet.
+		age := blockAge(
+			lnwire.NewShortChanIDFromInt(c.ChannelID).BlockHeight,
+			info.BlockHeight,
+		)
+		if age < window {
+			continue
+		}
+
+		ch := idleChannel{
+			chanID:   c.ChannelID,
+			peer:     c.PubKeyBytes,
+			active:   c.Active,
+			age:      age,
+			capacity: c.Capacity,
+			local:    c.LocalBalance,
+			uptime:   -1,
+		}
+		if c.LifeTime > 0 {
+			ch.uptime = float64(c.Uptime) / float64(c.LifeTime)
+		}
+		if c.Initiator {
+			ch.closeFee = closeFeeEstimate(c, satPerVByte)
+		}
+
+		idle = append(idle, ch)
+	}
+
+	if ctx.Bool("alias") {
+		peers := make([]route.Vertex, 0, len(idle))
+		for _, c := range idle {
+			peers = append(peers, c.peer)
+		}
+
+		aliases, err := lookupAliases(ctxb, client.Client, peers)
+		if err != nil {
+			return err
+		}
+		for i := range idle {
+			idle[i].alias = aliases[idle[i].peer]
+		}
+	}
+
+	// Closing the channels which give back the most are worth it first,
+	// among equals those whose peer is online the least.
+	sort.SliceStable(idle, func(i, j int) bool {
+		if idle[i].freed() != idle[j].freed() {
+			return idle[i].freed() > idle[j].freed()
+		}
+
+		return idle[i].uptime < idle[j].uptime
+	})
+
+	printIdle(idle, &rowFormat{chanFmt: chanFmt, unit: unit})
+
+	return nil
+}
+
+// printIdle prints the idle channels followed by their totals.
+func printIdle(idle []idleChannel, f *rowFormat) {
+	table := newTextTable(
+		"Rank", "Channel ID", "Peer", "Active", "Age", "Capacity",
+		"Local", "Uptime", "Close Fee", "Freed",
+	)
+	table.alignLeft(2)
+
+	var capacity, local, closeFee btcutil.Amount
+	for i, c := range idle {
+		peer := c.alias
+		if peer == "" {
+			peer = hex.EncodeToString(c.peer[:4])
+		}
+
+		active := "yes"
+		if !c.active {
+			active = "no"
+		}
+
+		uptime := "-"
+		if c.uptime >= 0 {
+			uptime = fmt.Sprintf("%.1f%%", c.uptime*100)
+		}
+
+		table.addRow(
+			fmt.Sprint(i+1),
+			f.chanFmt.format(c.chanID),
+			truncate(peer, 10),
+			active,
+			fmt.Sprintf("%dd", int64(math.Round(c.age.Hours()/24))),
+			f.unit.format(sat(c.capacity)),
+			f.unit.format(sat(c.local)),
+			uptime,
+			f.unit.format(sat(c.closeFee)),
+			f.unit.formatSigned(int64(sat(c.freed()))),
+		)
+
+		capacity += c.capacity
+		local += c.local
+		closeFee += c.closeFee
+	}
+	table.setFooter(
+		fmt.Sprint(len(idle)), "", "", "", "",
+		f.unit.format(sat(capacity)),
+		f.unit.format(sat(local)),
+		"",
+		f.unit.format(sat(closeFee)),
+		f.unit.formatSigned(int64(sat(local-closeFee))),
+	)
+
+	table.print()
+}
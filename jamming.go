@@ -0,0 +1,400 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/routing/route"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// defaultLongHold is the hold time above which a forward counts as
+	// held for long unless --hold says otherwise. Honest payments resolve
+	// within seconds.
+	defaultLongHold = time.Minute
+
+	// defaultJamThreshold is the score from which a peer is suspected of
+	// jamming unless --threshold says otherwise.
+	defaultJamThreshold = 30
+
+	// minHoldSample is the number of forwards the share of long holds is
+	// taken over at least, so a single slow forward doesn't make a peer
+	// suspect.
+	minHoldSample = 10
+
+	// The weights of the share of long holds and of the share of slots
+	// in use in the jamming score.
+	holdWeight = 60
+	slotWeight = 40
+)
+
+// peerHolds sums up how long the forwards through a peer were held and how
+// many HTLC slots its channels use.
+type peerHolds struct {
+	peer     route.Vertex
+	channels int
+
+	// The forwards which came in from or went out to the peer, resolved
+	// in the window or held for long and still in flight at its end, and
+	// how many of them were held for long.
+	forwardsIn, forwardsOut int
+	longIn, longOut         int
+	holds                   []time.Duration
+
+	// inFlight are the HTLCs pending on the peer's channels, slots the
+	// largest share of slots in use on one of them in percent.
+	inFlight int
+	slots    float64
+}
+
+// holdShare returns the share of the forwards held for long, over at least
+// minHoldSample forwards.
+func (p *peerHolds) holdShare() float64 {
+	forwards := max(p.forwardsIn+p.forwardsOut, minHoldSample)
+	return float64(p.longIn+p.longOut) / float64(forwards)
+}
+
+// score rates how much the peer looks like it jams us, from 0 to 100.
+func (p *peerHolds) score() float64 {
+	return holdWeight*p.holdShare() + slotWeight*p.slots/100
+}
+
+// medianHold returns the median hold time of the peer's forwards. The holds
+// must be sorted and not empty.
+func (p *peerHolds) medianHold() time.Duration {
+	return p.holds[(len(p.holds)-1)/2]
+}
+
+// htlcSlot identifies an HTLC by the channel and index it is known by on
+// one side of a forward.
+type htlcSlot struct {
+	chanID, htlcIndex uint64
+}
+
+// forwardHold is a forward and how long it was held.
+type forwardHold struct {
+	forward htlcEvent
+	hold    time.Duration
+}
+
+// forwardHolds returns how long the forwards of the window were held, with
+// the number of those resolved in it and of those still in flight at its
+// end. pending are the HTLCs in flight now.
+//
+// Forwards which started before the window are still paired with their
+// resolution in it. A forward in flight at the end has been held for as
+// long as it is old by then, which only tells anything once that is longer
+// than longHold. It counts if it was resolved later or is still pending,
+// else lnb wasn't watching when it was resolved.
+func forwardHolds(events []htlcEvent, start, end time.Time,
+	longHold time.Duration, pending map[htlcSlot]bool) ([]forwardHold,
+	int, int) {
+
+	var (
+		holds         []forwardHold
+		resolved      int
+		atEnd         map[htlcKey]htlcEvent
+		resolvedLater = make(map[htlcKey]bool)
+	)
+	tracker := newHTLCTracker()
+	for i := range events {
+		e := &events[i]
+		if e.Type != htlcTypeForward {
+			continue
+		}
+
+		// The forwards in flight at the end are followed further, to
+		// tell those resolved later from those whose resolution was
+		// missed.
+		if e.Time.After(end) && atEnd == nil {
+			atEnd = maps.Clone(tracker.inFlight)
+		}
+
+		forward, ok := tracker.track(e)
+		switch {
+		case !ok || e.Time.Before(start):
+			continue
+
+		case e.Time.After(end):
+			resolvedLater[htlcKey{
+				forward.ChannelIn, forward.HtlcIn,
+				forward.ChannelOut, forward.HtlcOut,
+			}] = true
+			continue
+		}
+
+		resolved++
+		holds = append(holds, forwardHold{
+			forward: forward,
+			hold:    e.Time.Sub(forward.Time),
+		})
+	}
+	if atEnd == nil {
+		atEnd = tracker.inFlight
+	}
+
+	var unresolved int
+	for key, forward := range atEnd {
+		hold := end.Sub(forward.Time)
+		if hold <= longHold || hold > maxHTLCHold {
+			continue
+		}
+
+		if !resolvedLater[key] &&
+			!pending[htlcSlot{key.chanIn, key.htlcIn}] &&
+			!pending[htlcSlot{key.chanOut, key.htlcOut}] {
+
+			continue
+		}
+
+		unresolved++
+		holds = append(holds, forwardHold{forward: forward, hold: hold})
+	}
+
+	return holds, resolved, unresolved
+}
+
+// reportJamming scores the peers by how long the forwards through them
+// were held over the window and by the HTLC slots their channels use now.
+func reportJamming(ctx *cli.Context) error {
+	ctxb := ctx.Context
+	client, err := getClient(ctxb, ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to LND: %w", err)
+	}
+	defer client.Close()
+
+	loc, err := time.LoadLocation(ctx.String("tz"))
+	if err != nil {
+		return fmt.Errorf("invalid --tz: %w", err)
+	}
+	now := client.Now()
+
+	start, err := parseTimeSpec(ctx.String("since"), now, loc)
+	if err != nil {
+		return fmt.Errorf("invalid --since: %w", err)
+	}
+
+	end := now
+	if ctx.IsSet("until") {
+		end, err = parseTimeSpec(ctx.String("until"), now, loc)
+		if err != nil {
+			return fmt.Errorf("invalid --until: %w", err)
+		}
+	}
+
+	longHold := ctx.Duration("hold")
+	if longHold <= 0 {
+		return fmt.Errorf("--hold should be positive")
+	}
+
+	threshold := ctx.Float64("threshold")
+	if threshold < 0 || threshold > 100 {
+		return fmt.Errorf("--threshold should be between 0 and 100")
+	}
+
+	var (
+		channels []lndclient.ChannelInfo
+		closed   []lndclient.ClosedChannel
+		events   []htlcEvent
+		store    *nodeStore
+	)
+	g, gctx := errgroup.WithContext(ctxb)
+	g.Go(func() error {
+		var err error
+		channels, err = client.Client.ListChannels(gctx, false, false)
+		return err
+	})
+	g.Go(func() error {
+		var err error
+		closed, err = client.Client.ClosedChannels(gctx)
+		return err
+	})
+	g.Go(func() error {
+		var err error
+		store, err = connectedStore(gctx, ctx, client.Client)
+		if err != nil {
+			return err
+		}
+
+		events, err = readRecords[htlcEvent](store, htlcEventsFile)
+		return err
+	})
+	if err := g.Wait(); err != nil {
+		return err
+	}
+
+	peerOf := make(map[uint64]route.Vertex, len(channels)+len(closed))
+	for _, c := range closed {
+		peerOf[c.ChannelID] = c.PubKeyBytes
+	}
+	for _, c := range channels {
+		peerOf[c.ChannelID] = c.PubKeyBytes
+	}
+
+	peers := make(map[route.Vertex]*peerHolds)
+	peerHold := func(node route.Vertex) *peerHolds {
+		p, ok := peers[node]
+		if !ok {
+			p = &peerHolds{peer: node}
+			peers[node] = p
+		}
+
+		return p
+	}
+
+	for i := range channels {
+		c := &channels[i]
+		p := peerHold(c.PubKeyBytes)
+		p.channels++
+		p.inFlight += len(c.PendingHtlcs)
+		p.slots = max(p.slots, newSlotUsage(c).share())
+	}
+
+	// HTLCs pending now, by the channel and index they are known by on
+	// either side.
+	pending := make(map[htlcSlot]bool)
+	for _, c := range channels {
+		for _, h := range c.PendingHtlcs {
+			pending[htlcSlot{c.ChannelID, h.HtlcIndex}] = true
+		}
+	}
+
+	holds, resolved, unresolved := forwardHolds(
+		events, start, end, longHold, pending,
+	)
+	for _, h := range holds {
+		long := h.hold > longHold
+
+		if node, ok := peerOf[h.forward.ChannelIn]; ok {
+			p := peerHold(node)
+			p.forwardsIn++
+			p.holds = append(p.holds, h.hold)
+			if long {
+				p.longIn++
+			}
+		}
+		if node, ok := peerOf[h.forward.ChannelOut]; ok {
+			p := peerHold(node)
+			p.forwardsOut++
+			p.holds = append(p.holds, h.hold)
+			if long {
+				p.longOut++
+			}
+		}
+	}
+
+	// Peers we neither have channels with nor forwarded through in the
+	// window don't tell anything.
+	sorted := make([]*peerHolds, 0, len(peers))
+	for _, p := range peers {
+		if p.forwardsIn+p.forwardsOut == 0 && p.inFlight == 0 {
+			continue
+		}
+
+		sort.Slice(p.holds, func(i, j int) bool {
+			return p.holds[i] < p.holds[j]
+		})
+		sorted = append(sorted, p)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].score() != sorted[j].score() {
+			return sorted[i].score() > sorted[j].score()
+		}
+
+		return bytes.Compare(sorted[i].peer[:], sorted[j].peer[:]) < 0
+	})
+
+	var suspects int
+	for _, p := range sorted {
+		if p.score() >= threshold {
+			suspects++
+		}
+	}
+
+	fmt.Printf("%d forwards resolved from %s to %s and %d still in "+
+		"flight, held for long above %v, %d peers suspected of "+
+		"jamming\n", resolved, start.In(loc).Format(time.DateOnly),
+		end.In(loc).Format(time.DateOnly), unresolved, longHold,
+		suspects)
+	if len(events) == 0 {
+		fmt.Printf("No HTLC events in %s, run lnb watch htlcs to "+
+			"record hold times.\n", store.path(htlcEventsFile))
+	}
+	if len(sorted) == 0 {
+		return nil
+	}
+
+	var aliases map[route.Vertex]string
+	if ctx.Bool("alias") {
+		nodes := make([]route.Vertex, 0, len(sorted))
+		for _, p := range sorted {
+			nodes = append(nodes, p.peer)
+		}
+
+		aliases, err = lookupAliases(ctxb, client.Client, nodes)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Hold times of a second or more are rounded to the second.
+	formatHold := func(d time.Duration) string {
+		if d >= time.Second {
+			return d.Round(time.Second).String()
+		}
+
+		return d.Round(time.Millisecond).String()
+	}
+
+	fmt.Println()
+	table := newTextTable(
+		"Peer", "Channels", "Forwards", "Long In", "Long Out",
+		"Median Hold", "Max Hold", "In Flight", "Slot Use", "Score", "",
+	)
+	table.alignLeft(0, 10)
+
+	for _, p := range sorted {
+		label := hex.EncodeToString(p.peer[:4])
+		if alias := aliases[p.peer]; alias != "" {
+			label = truncate(alias, 10)
+		}
+
+		var medianHold, maxHold string
+		if len(p.holds) > 0 {
+			medianHold = formatHold(p.medianHold())
+			maxHold = formatHold(p.holds[len(p.holds)-1])
+		}
+
+		var flag string
+		if p.score() >= threshold {
+			flag = "!"
+		}
+
+		table.addRow(
+			label,
+			fmt.Sprint(p.channels),
+			fmt.Sprint(p.forwardsIn+p.forwardsOut),
+			fmt.Sprint(p.longIn),
+			fmt.Sprint(p.longOut),
+			medianHold,
+			maxHold,
+			fmt.Sprint(p.inFlight),
+			fmt.Sprintf("%.1f%%", p.slots),
+			fmt.Sprint(int64(math.Round(p.score()))),
+			flag,
+		)
+	}
+
+	table.print()
+
+	return nil
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"testing"
+	"time"
+)
+
+// TestPeerHoldsScore checks the share of long holds, taken over at least
+// minHoldSample forwards, and the score made of it and the slots in use.
+func TestPeerHoldsScore(t *testing.T) {
+	tests := []struct {
+		name  string
+		p     peerHolds
+		share float64
+		score float64
+	}{
+		{
+			name: "nothing",
+		},
+		{
+			name:  "single slow forward",
+			p:     peerHolds{forwardsIn: 1, longIn: 1},
+			share: 0.1,
+			score: 6,
+		},
+		{
+			name: "below the sample floor",
+			p: peerHolds{
+				forwardsIn: 3, forwardsOut: 2, longIn: 1,
+				longOut: 1,
+			},
+			share: 0.2,
+			score: 12,
+		},
+		{
+			name:  "at the sample floor",
+			p:     peerHolds{forwardsOut: 10, longOut: 10},
+			share: 1,
+			score: 60,
+		},
+		{
+			name: "above the sample floor",
+			p: peerHolds{
+				forwardsIn: 30, forwardsOut: 10, longIn: 10,
+			},
+			share: 0.25,
+			score: 15,
+		},
+		{
+			name:  "slots only",
+			p:     peerHolds{inFlight: 120, slots: 50},
+			score: 20,
+		},
+		{
+			name: "all held and full",
+			p: peerHolds{
+				forwardsIn: 20, longIn: 20, slots: 100,
+			},
+			share: 1,
+			score: 100,
+		},
+	}
+
+	for _, test := range tests {
+		if got := test.p.holdShare(); math.Abs(got-test.share) > 1e-9 {
+			t.Errorf("%s: hold share %v, want %v", test.name, got,
+				test.share)
+		}
+		if got := test.p.score(); math.Abs(got-test.score) > 1e-9 {
+			t.Errorf("%s: score %v, want %v", test.name, got,
+				test.score)
+		}
+	}
+}
+
+// TestForwardHolds checks which forwards count and for how long they were
+// held, resolved in the window or still in flight at its end.
+func TestForwardHolds(t *testing.T) {
+	start := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(24 * time.Hour)
+	longHold := time.Minute
+
+	var events []htlcEvent
+	forward := func(at time.Time, htlc uint64) {
+		events = append(events, *testForward(at, htlc, htlc))
+	}
+	settle := func(at time.Time, htlc uint64) {
+		events = append(
+			events, *testResolution(at, htlcSettle, htlc, htlc),
+		)
+	}
+
+	// Started before the window, settled in it.
+	forward(start.Add(-time.Hour), 1)
+	settle(start.Add(time.Hour), 1)
+
+	// Settled before the window.
+	forward(start.Add(-2*time.Hour), 2)
+	settle(start.Add(-time.Hour), 2)
+
+	// Settled in seconds.
+	forward(start.Add(2*time.Hour), 3)
+	settle(start.Add(2*time.Hour+5*time.Second), 3)
+
+	// Held over the end and settled after it.
+	forward(end.Add(-time.Hour), 4)
+
+	// Still pending now.
+	forward(end.Add(-2*time.Hour), 5)
+
+	// Its resolution was missed.
+	forward(end.Add(-3*time.Hour), 6)
+
+	// In flight at the end, but not for long yet.
+	forward(end.Add(-30*time.Second), 7)
+
+	// After the window.
+	forward(end.Add(time.Minute), 8)
+	settle(end.Add(2*time.Hour), 4)
+
+	pending := map[htlcSlot]bool{{chanID: 2, htlcIndex: 5}: true}
+
+	holds, resolved, unresolved := forwardHolds(
+		events, start, end, longHold, pending,
+	)
+	if resolved != 2 || unresolved != 2 {
+		t.Fatalf("%d resolved and %d unresolved forwards, want 2 and 2",
+			resolved, unresolved)
+	}
+
+	sort.Slice(holds, func(i, j int) bool {
+		return holds[i].forward.HtlcIn < holds[j].forward.HtlcIn
+	})
+	want := []struct {
+		htlc uint64
+		hold time.Duration
+	}{
+		{1, 2 * time.Hour},
+		{3, 5 * time.Second},
+		{4, time.Hour},
+		{5, 2 * time.Hour},
+	}
+	if len(holds) != len(want) {
+		t.Fatalf("got %d holds, want %d: %+v", len(holds), len(want),
+			holds)
+	}
+	for i, w := range want {
+		h := holds[i]
+		if h.forward.HtlcIn != w.htlc || h.hold != w.hold {
+			t.Errorf("forward %d held for %v, want forward %d held "+
+				"for %v", h.forward.HtlcIn, h.hold, w.htlc, w.hold)
+		}
+	}
+}
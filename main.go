@@ -4,9 +4,11 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"os/signal"
 	"os/user"
 	"path/filepath"
 	"strings"
+	"syscall"
 
 	"github.com/lightninglabs/lndclient"
 	"github.com/lightningnetwork/lnd/lnrpc/verrpc"
@@ -21,6 +23,7 @@ const (
 	defaultMacaroonFilename = "admin.macaroon"
 	defaultRPCPort          = "10009"
 	defaultRPCHostPort      = "localhost:" + defaultRPCPort
+	defaultNetwork          = "mainnet"
 )
 
 var (
@@ -59,32 +62,33 @@ func cleanAndExpandPath(path string) string {
 	return filepath.Clean(os.ExpandEnv(path))
 }
 
-func getClient(callerCtx context.Context, ctx *cli.Context) (*lndclient.GrpcLndServices, error) {
+// connectLnd connects to the live lnd daemon set up by the global flags.
+func connectLnd(callerCtx context.Context, ctx *cli.Context) (*lndServices,
+	error) {
+
 	// We'll now fetch the lnddir so we can make a decision  on how to
 	// properly read the macaroons (if needed) and also the cert. This will
 	// either be the default, or will have been overwritten by the end
 	// user.
 	lndDir := cleanAndExpandPath(ctx.String("lnddir"))
 
-	network := strings.ToLower(ctx.String("network"))
-
-	// If the macaroon path as been manually provided, then we'll only
-	// target the specified file.
-	var macPath string
-	if ctx.String("macaroonpath") != "" {
-		macPath = cleanAndExpandPath(ctx.String("macaroonpath"))
-	} else {
-		// Otherwise, we'll go into the path:
-		// lnddir/data/chain/<chain>/<network> in order to fetch the
-		// macaroon that we need.
-		macPath = filepath.Join(
-			lndDir, defaultDataDir, defaultChainSubDir, "bitcoin",
-			network, defaultMacaroonFilename,
-		)
+	macPath, network, err := resolveMacaroon(ctx, lndDir)
+	if err != nil {
+		return nil, err
 	}
 
 	tlsCertPath := cleanAndExpandPath(ctx.String("tlscertpath"))
 
+	// If a proxy was requested, all connections to the RPC server are
+	// dialed through it. This is what makes onion-only nodes reachable.
+	var dialer lndclient.DialerFunc
+	if ctx.String("proxy") != "" {
+		dialer, err = newProxyDialer(ctx.String("proxy"))
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// If a custom lnd directory was set, we'll also check if custom paths
 	// for the TLS cert and macaroon file were set as well. If not, we'll
 	// override their paths so they can be found within the custom lnd
@@ -94,22 +98,64 @@ func getClient(callerCtx context.Context, ctx *cli.Context) (*lndclient.GrpcLndS
 		tlsCertPath = filepath.Join(lndDir, defaultTLSCertFilename)
 	}
 
-	return lndclient.NewLndServices(&lndclient.LndServicesConfig{
+	services, err := lndclient.NewLndServices(&lndclient.LndServicesConfig{
 		LndAddress:         ctx.String("rpcserver"),
-		Network:            lndclient.Network(ctx.String("network")),
+		Network:            lndclient.Network(network),
 		CustomMacaroonPath: macPath,
 		TLSPath:            tlsCertPath,
 		CheckVersion:       minRequiredLndVersion,
+		Dialer:             dialer,
 		CallerCtx:          callerCtx,
+		RPCTimeout:         ctx.Duration("timeout"),
 	})
+	if err != nil {
+		return nil, err
+	}
+
+	api := &lndClient{
+		LightningClient: services.Client,
+		router:          services.Router,
+	}
+	client := &lndServices{
+		Client: wrapClient(ctx, api),
+		close:  services.Close,
+	}
+
+	// The macaroon we picked only tells which network we expect, so make
+	// sure lnd agrees before anything is read from it.
+	info, err := client.Client.GetInfo(callerCtx)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	if info.Network != network {
+		client.Close()
+		return nil, fmt.Errorf("lnd is running on %s, but %s is for %s, "+
+			"set --network or --macaroonpath", info.Network, macPath,
+			network)
+	}
+
+	return client, nil
 }
 
-func main() {
+// newApp returns lnb's command line app.
+func newApp() *cli.App {
 	app := cli.NewApp()
 	app.EnableBashCompletion = true
 	app.Name = "lnb"
 	app.Usage = "lighting channel balancer for lnd"
 	app.Flags = []cli.Flag{
+		&cli.StringFlag{
+			Name:  "configfile",
+			Value: defaultLnbDir + "/" + defaultConfigFilename,
+			Usage: "path to lnb's config file",
+		},
+		&cli.StringFlag{
+			Name:  "lnbdir",
+			Value: defaultLnbDir,
+			Usage: "path to the directory lnb keeps the history it " +
+				"records in",
+		},
 		&cli.StringFlag{
 			Name:  "rpcserver",
 			Value: defaultRPCHostPort,
@@ -125,10 +171,11 @@ func main() {
 			Usage: "path to lnd's tls.cert",
 		},
 		&cli.StringFlag{
-			Name: "network, n",
+			Name:    "network",
+			Aliases: []string{"n"},
 			Usage: "the network lnd is running on e.g. mainnet, " +
 				"testnet, etc.",
-			Value: "mainnet",
+			DefaultText: "detected from the macaroons in lnddir",
 		},
 		&cli.BoolFlag{
 			Name:  "no-macaroons",
@@ -147,14 +194,159 @@ func main() {
 			Name:  "macaroonip",
 			Usage: "if set, lock macaroon to specific IP address",
 		},
+		&cli.StringFlag{
+			Name: "proxy",
+			Usage: "if set, connect to lnd, price sources and " +
+				"webhooks through this SOCKS5 proxy, e.g. " +
+				"socks5://127.0.0.1:9050 for Tor",
+		},
+		&cli.StringFlag{
+			Name:  "backend",
+			Value: lndBackend,
+			Usage: "where to get the node data from: lnd, or " +
+				"fixture:<dir> to serve it from a recording",
+		},
+		&cli.StringFlag{
+			Name:  "chan-format",
+			Value: chanFormatColon,
+			Usage: "how channel ids are printed: colon (bbbbbb:iiii:p), " +
+				"scid (bbbbbbxiiiixp) or int (numeric id)",
+		},
+		&cli.StringFlag{
+			Name:  "unit",
+			Value: unitSat,
+			Usage: "the unit amounts are printed in: msat, sat or btc",
+		},
+		&cli.StringFlag{
+			Name: "fiat",
+			Usage: "if set, also value fees in this currency, e.g. USD, " +
+				"at the price of the day they were earned",
+		},
+		&cli.StringFlag{
+			Name: "price-source",
+			Usage: "where --fiat prices come from: a file of " +
+				"date,currency,price lines, or an http(s) URL " +
+				"with {currency} and {date} placeholders",
+		},
+		&cli.DurationFlag{
+			Name:  "timeout",
+			Value: defaultRPCTimeout,
+			Usage: "the time to wait for each RPC call to lnd",
+		},
+		&cli.DurationFlag{
+			Name:  "http-timeout",
+			Value: defaultHTTPTimeout,
+			Usage: "the time to wait for each HTTP request to a " +
+				"price source or webhook",
+		},
+		&cli.IntFlag{
+			Name:  "retries",
+			Value: defaultRPCRetries,
+			Usage: "the number of times an RPC call is retried if lnd " +
+				"is unavailable or doesn't answer in time",
+		},
+		&cli.IntFlag{
+			Name:  "parallel",
+			Value: defaultRPCParallel,
+			Usage: "the maximum number of RPC calls to lnd in flight at " +
+				"the same time",
+		},
+		&cli.BoolFlag{
+			Name:  "timings",
+			Usage: "report the time spent in each RPC call on stderr",
+		},
+	}
+	app.Before = loadConfig
+	app.After = func(ctx *cli.Context) error {
+		if ctx.Bool("timings") {
+			rpcStats.print(os.Stderr)
+		}
+
+		return nil
 	}
 	app.Commands = []*cli.Command{
 		&getCommand,
 		&listCommand,
+		&reportCommand,
+		&snapshotCommand,
+		&watchCommand,
+		&checkCommand,
+		&recordCommand,
 	}
 
-	if err := app.Run(os.Args); err != nil {
-		fmt.Fprintf(os.Stderr, "[lnb] %v\n", err)
-		os.Exit(1)
+	return app
+}
+
+func main() {
+	app := newApp()
+
+	// The root context is cancelled on Ctrl-C or SIGTERM, which aborts
+	// whatever RPC is in flight instead of killing the process mid-way.
+	ctx, stop := signal.NotifyContext(
+		context.Background(), os.Interrupt, syscall.SIGTERM,
+	)
+	defer stop()
+
+	if err := app.RunContext(ctx, os.Args); err != nil {
+		if ctx.Err() != nil {
+			fmt.Fprintln(os.Stderr, "[lnb] interrupted")
+			stop()
+			os.Exit(130)
+		}
+
+		code := exitCode(app, os.Args)
+		if code == checkUnknown {
+			fmt.Printf("LND %s - %v\n", checkStateNames[code], err)
+		} else {
+			fmt.Fprintf(os.Stderr, "[lnb] %v\n", err)
+		}
+		stop()
+		os.Exit(code)
+	}
+}
+
+// exitCode returns the code lnb exits with when running args failed before
+// or outside of a command's own exit handling. Monitoring systems take any
+// code but those of the states as a broken plugin, so lnb check reports
+// errors like an invalid config file as UNKNOWN.
+func exitCode(app *cli.App, args []string) int {
+	if commandName(app, args) == checkCommand.Name {
+		return checkUnknown
 	}
+
+	return 1
+}
+
+// commandName returns the name of the command args run, skipping the
+// global flags in front of it, or an empty string if there is none.
+func commandName(app *cli.App, args []string) string {
+	takesValue := make(map[string]bool)
+	for _, flag := range app.Flags {
+		_, isBool := flag.(*cli.BoolFlag)
+		for _, name := range flag.Names() {
+			takesValue[name] = !isBool
+		}
+	}
+
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			if i+1 < len(args) {
+				return args[i+1]
+			}
+			return ""
+
+		case strings.HasPrefix(arg, "-"):
+			name := strings.TrimLeft(arg, "-")
+			if !strings.Contains(name, "=") && takesValue[name] {
+				i++
+			}
+
+		default:
+			return arg
+		}
+	}
+
+	return ""
 }
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+// macaroonLocation is an admin macaroon found below lnd's chain directory
+// together with the chain and network it was created for.
+type macaroonLocation struct {
+	path    string
+	chain   string
+	network string
+}
+
+// findMacaroons scans lnddir/data/chain/<chain>/<network> for admin
+// macaroons.
+func findMacaroons(lndDir string) ([]macaroonLocation, error) {
+	pattern := filepath.Join(
+		lndDir, defaultDataDir, defaultChainSubDir, "*", "*",
+		defaultMacaroonFilename,
+	)
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	locations := make([]macaroonLocation, 0, len(matches))
+	for _, path := range matches {
+		networkDir := filepath.Dir(path)
+		locations = append(locations, macaroonLocation{
+			path:    path,
+			chain:   filepath.Base(filepath.Dir(networkDir)),
+			network: filepath.Base(networkDir),
+		})
+	}
+
+	return locations, nil
+}
+
+// networkFromMacaroonPath returns the network of a macaroon stored in lnd's
+// usual data/chain/<chain>/<network> layout, or an empty string if the path
+// doesn't follow that layout.
+func networkFromMacaroonPath(macPath string) string {
+	networkDir := filepath.Dir(macPath)
+	chainDir := filepath.Dir(filepath.Dir(networkDir))
+
+	if filepath.Base(chainDir) != defaultChainSubDir {
+		return ""
+	}
+
+	return filepath.Base(networkDir)
+}
+
+// resolveMacaroon decides which macaroon to use and which network lnd is
+// expected to run on. An explicit --macaroonpath always wins. Otherwise the
+// chain directory is searched, narrowed down to --network if it is set, and
+// the macaroon found is used if it is the only candidate.
+func resolveMacaroon(ctx *cli.Context, lndDir string) (string, string,
+	error) {
+
+	network := strings.ToLower(ctx.String("network"))
+
+	// If the macaroon path as been manually provided, then we'll only
+	// target the specified file.
+	if ctx.String("macaroonpath") != "" {
+		macPath := cleanAndExpandPath(ctx.String("macaroonpath"))
+		if network == "" {
+			network = networkFromMacaroonPath(macPath)
+		}
+		if network == "" {
+			network = defaultNetwork
+		}
+
+		return macPath, network, nil
+	}
+
+	// Otherwise, we'll go into the path:
+	// lnddir/data/chain/<chain>/<network> in order to fetch the macaroon
+	// that we need.
+	locations, err := findMacaroons(lndDir)
+	if err != nil {
+		return "", "", fmt.Errorf("unable to search for macaroons: %w",
+			err)
+	}
+
+	// If the network was given, only its macaroons are candidates. Should
+	// there be none, we still point to the bitcoin chain directory so the
+	// error lnd reports names the file which was expected.
+	if network != "" {
+		var matching []macaroonLocation
+		for _, l := range locations {
+			if l.network == network {
+				matching = append(matching, l)
+			}
+		}
+
+		if len(matching) == 0 {
+			macPath := filepath.Join(
+				lndDir, defaultDataDir, defaultChainSubDir,
+				"bitcoin", network, defaultMacaroonFilename,
+			)
+
+			return macPath, network, nil
+		}
+
+		locations = matching
+	}
+
+	switch len(locations) {
+	case 0:
+		return "", "", fmt.Errorf("no %s found in %s, set --network "+
+			"or --macaroonpath", defaultMacaroonFilename,
+			filepath.Join(lndDir, defaultDataDir, defaultChainSubDir))
+
+	case 1:
+		return locations[0].path, locations[0].network, nil
+	}
+
+	var list strings.Builder
+	for _, l := range locations {
+		fmt.Fprintf(&list, "\n  %s (chain %s, network %s)", l.path,
+			l.chain, l.network)
+	}
+
+	return "", "", fmt.Errorf("found %d macaroons, choose one with "+
+		"--network or --macaroonpath:%s", len(locations), list.String())
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/shopspring/decimal"
+	"github.com/urfave/cli/v2"
+)
+
+// priceDayLayout is the format of the days in price files and in the
+// {date} placeholder of price URLs.
+const priceDayLayout = "2006-01-02"
+
+// maxPriceResponse is the largest response accepted from a price URL.
+const maxPriceResponse = 1 << 16
+
+// priceSource provides the price of one bitcoin in a fiat currency.
+type priceSource interface {
+	// price returns the price on the given day, which is midnight UTC.
+	price(ctx context.Context, currency string, day time.Time) (
+		decimal.Decimal, error)
+}
+
+// dayPrice is the price of one bitcoin on a day.
+type dayPrice struct {
+	day   time.Time
+	price decimal.Decimal
+}
+
+// filePriceSource serves prices from a local price history, so reports are
+// reproducible. The file has one date,currency,price line per day, e.g.
+//
+//	2026-09-01,USD,61234.50
+//
+// Empty lines, lines starting with # and a header line are skipped.
+type filePriceSource struct {
+	// prices holds the prices of each currency sorted by day.
+	prices map[string][]dayPrice
+}
+
+// newFilePriceSource reads the price history file path.
+func newFilePriceSource(path string) (*filePriceSource, error) {
+	f, err := os.Open(cleanAndExpandPath(path))
+	if err != nil {
+		return nil, fmt.Errorf("unable to open price file: %w", err)
+	}
+	defer f.Close()
+
+	s := &filePriceSource{prices: make(map[string][]dayPrice)}
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, ",")
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("%s:%d: expected date,currency,"+
+				"price", path, n)
+		}
+
+		day, err := time.Parse(priceDayLayout, strings.TrimSpace(fields[0]))
+		if err != nil {
+			// The first line may name the columns.
+			if n == 1 {
+				continue
+			}
+
+			return nil, fmt.Errorf("%s:%d: invalid date: %w", path, n,
+				err)
+		}
+
+		price, err := decimal.NewFromString(strings.TrimSpace(fields[2]))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid price: %w", path, n,
+				err)
+		}
+
+		currency := strings.ToUpper(strings.TrimSpace(fields[1]))
+		s.prices[currency] = append(s.prices[currency], dayPrice{
+			day:   day,
+			price: price,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read price file: %w", err)
+	}
+
+	for _, prices := range s.prices {
+		sort.Slice(prices, func(i, j int) bool {
+			return prices[i].day.Before(prices[j].day)
+		})
+	}
+
+	return s, nil
+}
+
+// price returns the price of the day, or of the last day before it which
+// has one if the history has a gap.
+func (s *filePriceSource) price(_ context.Context, currency string,
+	day time.Time) (decimal.Decimal, error) {
+
+	prices := s.prices[currency]
+	i := sort.Search(len(prices), func(i int) bool {
+		return prices[i].day.After(day)
+	})
+	if i == 0 {
+		return decimal.Zero, fmt.Errorf("no %s price for %s in the "+
+			"price file", currency, day.Format(priceDayLayout))
+	}
+
+	return prices[i-1].price, nil
+}
+
+// httpPriceSource fetches prices from an HTTP endpoint. The {currency} and
+// {date} placeholders of the URL are replaced by the currency and the day,
+// e.g. https://prices.example.com/btc/{currency}/{date}. The response is
+// either a bare number or a JSON object with a price member.
+type httpPriceSource struct {
+	url    string
+	client *http.Client
+}
+
+// price fetches the price of the day.
+func (s *httpPriceSource) price(ctx context.Context, currency string,
+	day time.Time) (decimal.Decimal, error) {
+
+	u := strings.NewReplacer(
+		"{currency}", url.PathEscape(currency),
+		"{date}", day.Format(priceDayLayout),
+	).Replace(s.url)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return decimal.Zero, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("unable to fetch price: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxPriceResponse))
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("unable to fetch price: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return decimal.Zero, fmt.Errorf("unable to fetch price from "+
+			"%s: %s", u, resp.Status)
+	}
+
+	text := strings.TrimSpace(string(body))
+	if price, err := decimal.NewFromString(text); err == nil {
+		return price, nil
+	}
+
+	var obj struct {
+		Price *decimal.Decimal `json:"price"`
+	}
+	if err := json.Unmarshal(body, &obj); err != nil || obj.Price == nil {
+		return decimal.Zero, fmt.Errorf("unexpected price response "+
+			"from %s: %q", u, truncate(text, 80))
+	}
+
+	return *obj.Price, nil
+}
+
+// newPriceSource returns the price source given with --price-source: an
+// http(s) URL or the path of a price file.
+func newPriceSource(ctx *cli.Context) (priceSource, error) {
+	src := ctx.String("price-source")
+	switch {
+	case src == "":
+		return nil, fmt.Errorf("--fiat needs a --price-source")
+
+	case strings.HasPrefix(src, "http://"),
+		strings.HasPrefix(src, "https://"):
+
+		client, err := newHTTPClient(
+			ctx.String("proxy"), ctx.Duration("http-timeout"),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		return &httpPriceSource{url: src, client: client}, nil
+
+	default:
+		return newFilePriceSource(src)
+	}
+}
+
+// fiatValuer values amounts in the --fiat currency at the price of the day
+// they moved. Prices are cached per day, so a source is asked once per day
+// no matter how many amounts fall on it.
+type fiatValuer struct {
+	currency string
+	source   priceSource
+
+	mu     sync.Mutex
+	prices map[time.Time]decimal.Decimal
+}
+
+// newFiatValuer returns the valuer for --fiat, nil if it isn't set.
+func newFiatValuer(ctx *cli.Context) (*fiatValuer, error) {
+	currency := strings.ToUpper(ctx.String("fiat"))
+	if currency == "" {
+		return nil, nil
+	}
+
+	source, err := newPriceSource(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fiatValuer{
+		currency: currency,
+		source:   source,
+		prices:   make(map[time.Time]decimal.Decimal),
+	}, nil
+}
+
+// value returns the fiat value of the amount at the price of the UTC day of
+// t.
+func (v *fiatValuer) value(ctx context.Context, msat lnwire.MilliSatoshi,
+	t time.Time) (decimal.Decimal, error) {
+
+	y, m, d := t.UTC().Date()
+	day := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	price, ok := v.prices[day]
+	if !ok {
+		var err error
+		price, err = v.source.price(ctx, v.currency, day)
+		if err != nil {
+			return decimal.Zero, err
+		}
+		v.prices[day] = price
+	}
+
+	return decimal.NewFromInt(int64(msat)).Mul(price).Div(msatPerBTC), nil
+}
+
+// formatFiat prints a fiat amount with cents.
+func formatFiat(amt decimal.Decimal) string {
+	return amt.StringFixedBank(2)
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/routing/route"
+	"github.com/shopspring/decimal"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/sync/errgroup"
+)
+
+// The periods report profit can sum up by, see --period.
+const (
+	periodDaily   = "daily"
+	periodWeekly  = "weekly"
+	periodMonthly = "monthly"
+)
+
+// periodStart returns the start of the period t falls into: midnight, the
+// Monday of the week or the first of the month in loc.
+func periodStart(t time.Time, period string, loc *time.Location) time.Time {
+	y, m, d := t.In(loc).Date()
+
+	switch period {
+	case periodWeekly:
+		day := time.Date(y, m, d, 0, 0, 0, 0, loc)
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset)
+
+	case periodMonthly:
+		return time.Date(y, m, 1, 0, 0, 0, 0, loc)
+
+	default:
+		return time.Date(y, m, d, 0, 0, 0, 0, loc)
+	}
+}
+
+// nextPeriod returns the start of the period after the one starting at t.
+func nextPeriod(t time.Time, period string) time.Time {
+	switch period {
+	case periodWeekly:
+		return t.AddDate(0, 0, 7)
+
+	case periodMonthly:
+		return t.AddDate(0, 1, 0)
+
+	default:
+		return t.AddDate(0, 0, 1)
+	}
+}
+
+// periodLabel names the period starting at t.
+func periodLabel(t time.Time, period string) string {
+	switch period {
+	case periodWeekly:
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+
+	case periodMonthly:
+		return t.Format("2006-01")
+
+	default:
+		return t.Format("2006-01-02")
+	}
+}
+
+// periodProfit sums up what the node earned and spent in a period.
+type periodProfit struct {
+	start time.Time
+
+	forwards      int
+	volume        lnwire.MilliSatoshi
+	earned        lnwire.MilliSatoshi
+	rebalanceFees lnwire.MilliSatoshi
+	chainFees     btcutil.Amount
+
+	// netFiat is the net profit valued at the prices of the days the
+	// fees were earned and spent, if --fiat is set.
+	netFiat decimal.Decimal
+}
+
+// net returns the fees earned minus all fees spent, in millisatoshis.
+func (p *periodProfit) net() int64 {
+	return int64(p.earned) - int64(p.rebalanceFees) -
+		int64(sat(p.chainFees))
+}
+
+// reportProfit prints the net routing profit per period.
+func reportProfit(ctx *cli.Context) error {
+	ctxb := ctx.Context
+	client, err := getClient(ctxb, ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to LND: %w", err)
+	}
+	defer client.Close()
+
+	unit, err := getAmountUnit(ctx)
+	if err != nil {
+		return err
+	}
+
+	fiat, err := newFiatValuer(ctx)
+	if err != nil {
+		return err
+	}
+
+	period := ctx.String("period")
+	switch period {
+	case periodDaily, periodWeekly, periodMonthly:
+	default:
+		return fmt.Errorf("invalid --period %q, should be %s, %s or %s",
+			period, periodDaily, periodWeekly, periodMonthly)
+	}
+
+	loc, err := time.LoadLocation(ctx.String("tz"))
+	if err != nil {
+		return fmt.Errorf("invalid --tz: %w", err)
+	}
+	now := client.Now()
+
+	since, err := parseTimeSpec(ctx.String("since"), now, loc)
+	if err != nil {
+		return fmt.Errorf("invalid --since: %w", err)
+	}
+
+	end := now
+	if ctx.IsSet("until") {
+		end, err = parseTimeSpec(ctx.String("until"), now, loc)
+		if err != nil {
+			return fmt.Errorf("invalid --until: %w", err)
+		}
+	}
+
+	// Whole periods are reported, so the first one starts before --since.
+	start := periodStart(since, period, loc)
+
+	var (
+		forwards   []lndclient.ForwardingEvent
+		rebalances []rebalance
+		channels   []lndclient.ChannelInfo
+		closed     []lndclient.ClosedChannel
+		txs        []lndclient.Transaction
+	)
+	g, gctx := errgroup.WithContext(ctxb)
+	g.Go(func() error {
+		var err error
+		forwards, err = fetchForwards(gctx, client.Client, start, end)
+		return err
+	})
+	g.Go(func() error {
+		info, err := client.Client.GetInfo(gctx)
+		if err != nil {
+			return err
+		}
+
+		rebalances, err = fetchRebalances(
+			gctx, client.Client, route.Vertex(info.IdentityPubkey),
+			start, end,
+		)
+		return err
+	})
+	g.Go(func() error {
+		var err error
+		channels, err = client.Client.ListChannels(gctx, false, false)
+		return err
+	})
+	g.Go(func() error {
+		var err error
+		closed, err = client.Client.ClosedChannels(gctx)
+		return err
+	})
+	g.Go(func() error {
+		var err error
+		txs, err = client.Client.ListTransactions(gctx, 0, -1)
+		return err
+	})
+	if err := g.Wait(); err != nil {
+		return err
+	}
+
+	var periods []*periodProfit
+	index := make(map[time.Time]*periodProfit)
+	for t := start; t.Before(end); t = nextPeriod(t, period) {
+		p := &periodProfit{start: t}
+		periods = append(periods, p)
+		index[t] = p
+	}
+
+	// periodOf returns the period t falls into, nil if it is out of
+	// range.
+	periodOf := func(t time.Time) *periodProfit {
+		if t.Before(start) || !t.Before(end) {
+			return nil
+		}
+
+		return index[periodStart(t, period, loc)]
+	}
+
+	// addFiat adds the value of msat at the price of t to the net fiat
+	// profit of p, negated for spending.
+	addFiat := func(p *periodProfit, msat lnwire.MilliSatoshi, t time.Time,
+		spent bool) error {
+
+		if fiat == nil {
+			return nil
+		}
+
+		v, err := fiat.value(ctxb, msat, t)
+		if err != nil {
+			return err
+		}
+		if spent {
+			v = v.Neg()
+		}
+		p.netFiat = p.netFiat.Add(v)
+
+		return nil
+	}
+
+	for _, e := range forwards {
+		p := periodOf(e.Timestamp)
+		if p == nil {
+			continue
+		}
+
+		p.forwards++
+		p.volume += e.AmountMsatOut
+		p.earned += e.FeeMsat
+		if err := addFiat(p, e.FeeMsat, e.Timestamp, false); err != nil {
+			return err
+		}
+	}
+
+	for _, r := range rebalances {
+		p := periodOf(r.time)
+		if p == nil {
+			continue
+		}
+
+		p.rebalanceFees += r.fee
+		if err := addFiat(p, r.fee, r.time, true); err != nil {
+			return err
+		}
+	}
+
+	// Only the opens and closes of channels are routing costs, the same
+	// as report roi charges them.
+	chanTxs := newChannelTxs(channels, closedWithLife(closed), txs)
+	for _, f := range chanTxs.chainFees() {
+		p := periodOf(f.time)
+		if p == nil {
+			continue
+		}
+
+		p.chainFees += f.fee
+		if err := addFiat(p, sat(f.fee), f.time, true); err != nil {
+			return err
+		}
+	}
+
+	printProfit(periods, period, unit, fiat)
+
+	return nil
+}
+
+// printProfit prints the profit of each period with the running total.
+func printProfit(periods []*periodProfit, period string, unit amountUnit,
+	fiat *fiatValuer) {
+
+	headers := []string{
+		"Period", "Forwards", "Volume", "Earned", "Rebal Fees",
+		"Chain Fees", "Net", "Cumulative",
+	}
+	if fiat != nil {
+		headers = append(headers, "Net "+fiat.currency,
+			"Cumulative "+fiat.currency)
+	}
+
+	table := newTextTable(headers...)
+	table.alignLeft(0)
+
+	var (
+		total   periodProfit
+		cumFiat decimal.Decimal
+	)
+	for _, p := range periods {
+		total.forwards += p.forwards
+		total.volume += p.volume
+		total.earned += p.earned
+		total.rebalanceFees += p.rebalanceFees
+		total.chainFees += p.chainFees
+		cumFiat = cumFiat.Add(p.netFiat)
+
+		row := []string{
+			periodLabel(p.start, period),
+			fmt.Sprint(p.forwards),
+			unit.format(p.volume),
+			unit.format(p.earned),
+			unit.format(p.rebalanceFees),
+			unit.format(sat(p.chainFees)),
+			unit.formatSigned(p.net()),
+			unit.formatSigned(total.net()),
+		}
+		if fiat != nil {
+			row = append(row, formatFiat(p.netFiat),
+				formatFiat(cumFiat))
+		}
+		table.addRow(row...)
+	}
+
+	footer := []string{
+		"Total",
+		fmt.Sprint(total.forwards),
+		unit.format(total.volume),
+		unit.format(total.earned),
+		unit.format(total.rebalanceFees),
+		unit.format(sat(total.chainFees)),
+		unit.formatSigned(total.net()),
+		"",
+	}
+	if fiat != nil {
+		footer = append(footer, formatFiat(cumFiat), "")
+	}
+	table.setFooter(footer...)
+
+	table.print()
+}
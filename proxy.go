@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/lightninglabs/lndclient"
+	"golang.org/x/net/proxy"
+)
+
+// defaultHTTPTimeout is the time an HTTP request to a price source or a
+// webhook may take unless --http-timeout says otherwise.
+const defaultHTTPTimeout = 30 * time.Second
+
+// newProxyDialer returns a dial function which connects to the lnd RPC
+// server, or to HTTP servers, through the SOCKS5 proxy at proxyAddr. The
+// proxy address may be given either as socks5://[user:password@]host:port or
+// as a bare host:port. Host names, including .onion addresses, are handed to
+// the proxy as is and never resolved locally.
+func newProxyDialer(proxyAddr string) (lndclient.DialerFunc, error) {
+	if !strings.Contains(proxyAddr, "://") {
+		proxyAddr = "socks5://" + proxyAddr
+	}
+
+	u, err := url.Parse(proxyAddr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --proxy address: %w", err)
+	}
+
+	// The socks5h scheme is accepted as well since that is how curl and
+	// friends spell "let the proxy resolve host names", which is what we
+	// always do anyway.
+	if u.Scheme != "socks5" && u.Scheme != "socks5h" {
+		return nil, fmt.Errorf("invalid --proxy address: unsupported "+
+			"scheme %q, only socks5 is supported", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid --proxy address: missing host")
+	}
+
+	var auth *proxy.Auth
+	if u.User != nil {
+		password, _ := u.User.Password()
+		auth = &proxy.Auth{
+			User:     u.User.Username(),
+			Password: password,
+		}
+	}
+
+	dialer, err := proxy.SOCKS5("tcp", u.Host, auth, proxy.Direct)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create SOCKS5 dialer: %w", err)
+	}
+
+	contextDialer, ok := dialer.(proxy.ContextDialer)
+	if !ok {
+		return nil, fmt.Errorf("SOCKS5 dialer does not support contexts")
+	}
+
+	return func(ctx context.Context, addr string) (net.Conn, error) {
+		conn, err := contextDialer.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return nil, fmt.Errorf("unable to dial %s via proxy %s: %w",
+				addr, u.Host, err)
+		}
+
+		return conn, nil
+	}, nil
+}
+
+// newHTTPClient returns the client prices are fetched and webhooks posted
+// with. If proxyAddr is set, it connects through that SOCKS5 proxy like the
+// RPC connection does, so nothing bypasses Tor, and ignores the proxies of
+// the environment.
+func newHTTPClient(proxyAddr string,
+	timeout time.Duration) (*http.Client, error) {
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if proxyAddr != "" {
+		dial, err := newProxyDialer(proxyAddr)
+		if err != nil {
+			return nil, err
+		}
+
+		transport.Proxy = nil
+		transport.DialContext = func(ctx context.Context, _,
+			addr string) (net.Conn, error) {
+
+			return dial(ctx, addr)
+		}
+	}
+
+	return &http.Client{Transport: transport, Timeout: timeout}, nil
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// socksRequest is what the SOCKS5 stand-in was asked for.
+type socksRequest struct {
+	user, password string
+
+	// addrType is 3 for a host name the proxy has to resolve, 1 or 4
+	// for an address resolved by the client.
+	addrType byte
+	host     string
+	port     uint16
+}
+
+// serveSOCKS5 runs a SOCKS5 stand-in which accepts a single connection,
+// records the request and hands the tunneled connection to serve.
+func serveSOCKS5(t *testing.T, requests chan<- socksRequest,
+	serve func(conn net.Conn)) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		l.Close()
+	})
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		req, err := socksHandshake(conn)
+		if err != nil {
+			t.Errorf("SOCKS5 handshake failed: %v", err)
+			return
+		}
+		requests <- req
+
+		serve(conn)
+	}()
+
+	return l.Addr().String()
+}
+
+// socksHandshake answers the greeting, the optional username and password
+// and the connect request of a SOCKS5 client.
+func socksHandshake(conn net.Conn) (socksRequest, error) {
+	var req socksRequest
+
+	read := func(n int) ([]byte, error) {
+		buf := make([]byte, n)
+		_, err := io.ReadFull(conn, buf)
+		return buf, err
+	}
+
+	greeting, err := read(2)
+	if err != nil {
+		return req, err
+	}
+	methods, err := read(int(greeting[1]))
+	if err != nil {
+		return req, err
+	}
+
+	// Username and password are used if the client offers them.
+	method := byte(0x00)
+	for _, m := range methods {
+		if m == 0x02 {
+			method = 0x02
+		}
+	}
+	if _, err := conn.Write([]byte{0x05, method}); err != nil {
+		return req, err
+	}
+
+	if method == 0x02 {
+		head, err := read(2)
+		if err != nil {
+			return req, err
+		}
+		user, err := read(int(head[1]))
+		if err != nil {
+			return req, err
+		}
+		size, err := read(1)
+		if err != nil {
+			return req, err
+		}
+		password, err := read(int(size[0]))
+		if err != nil {
+			return req, err
+		}
+		req.user, req.password = string(user), string(password)
+
+		if _, err := conn.Write([]byte{0x01, 0x00}); err != nil {
+			return req, err
+		}
+	}
+
+	head, err := read(4)
+	if err != nil {
+		return req, err
+	}
+	if head[1] != 0x01 {
+		return req, fmt.Errorf("unexpected command %d", head[1])
+	}
+
+	req.addrType = head[3]
+	switch req.addrType {
+	case 0x01:
+		addr, err := read(net.IPv4len)
+		if err != nil {
+			return req, err
+		}
+		req.host = net.IP(addr).String()
+
+	case 0x03:
+		size, err := read(1)
+		if err != nil {
+			return req, err
+		}
+		name, err := read(int(size[0]))
+		if err != nil {
+			return req, err
+		}
+		req.host = string(name)
+
+	case 0x04:
+		addr, err := read(net.IPv6len)
+		if err != nil {
+			return req, err
+		}
+		req.host = net.IP(addr).String()
+
+	default:
+		return req, fmt.Errorf("unknown address type %d", req.addrType)
+	}
+
+	port, err := read(2)
+	if err != nil {
+		return req, err
+	}
+	req.port = binary.BigEndian.Uint16(port)
+
+	// Succeeded, bound to 0.0.0.0:0.
+	_, err = conn.Write([]byte{0x05, 0x00, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+	return req, err
+}
+
+// TestProxyDialerOnion checks that .onion hosts are handed to the proxy
+// unresolved, with the credentials of the proxy address.
+func TestProxyDialerOnion(t *testing.T) {
+	const onion = "bh3hnpjuqs2ggfsvjr2dymqnpwdrw4vx2bwtfw56dkqqyrgydc7ip3yd" +
+		".onion"
+
+	tests := []struct {
+		name     string
+		proxy    func(addr string) string
+		user     string
+		password string
+	}{
+		{
+			name: "bare host:port",
+			proxy: func(addr string) string {
+				return addr
+			},
+		},
+		{
+			name: "socks5h with credentials",
+			proxy: func(addr string) string {
+				return "socks5h://lnb:secret@" + addr
+			},
+			user:     "lnb",
+			password: "secret",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			requests := make(chan socksRequest, 1)
+			addr := serveSOCKS5(t, requests, func(conn net.Conn) {
+				_, _ = io.Copy(conn, conn)
+			})
+
+			dial, err := newProxyDialer(test.proxy(addr))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := context.WithTimeout(
+				context.Background(), 5*time.Second,
+			)
+			defer cancel()
+
+			conn, err := dial(ctx, onion+":10009")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			req := <-requests
+			if req.addrType != 0x03 || req.host != onion ||
+				req.port != 10009 {
+
+				t.Fatalf("proxy was asked for type %d %s:%d, "+
+					"want the host name %s:10009",
+					req.addrType, req.host, req.port, onion)
+			}
+			if req.user != test.user || req.password != test.password {
+				t.Fatalf("proxy got credentials %q:%q, want %q:%q",
+					req.user, req.password, test.user,
+					test.password)
+			}
+
+			// The connection is tunneled through the proxy.
+			if _, err := conn.Write([]byte("ping")); err != nil {
+				t.Fatal(err)
+			}
+			reply := make([]byte, 4)
+			if _, err := io.ReadFull(conn, reply); err != nil {
+				t.Fatal(err)
+			}
+			if string(reply) != "ping" {
+				t.Fatalf("got %q through the proxy, want ping",
+					reply)
+			}
+		})
+	}
+}
+
+// TestProxyDialerInvalid checks the proxy addresses which are rejected.
+func TestProxyDialerInvalid(t *testing.T) {
+	for _, proxy := range []string{
+		"http://127.0.0.1:8080",
+		"socks4://127.0.0.1:9050",
+		"socks5://",
+	} {
+		if _, err := newProxyDialer(proxy); err == nil {
+			t.Errorf("proxy %q was accepted", proxy)
+		}
+	}
+}
+
+// TestHTTPClientProxy checks that HTTP requests go through the proxy with
+// the host name unresolved.
+func TestHTTPClientProxy(t *testing.T) {
+	requests := make(chan socksRequest, 1)
+	addr := serveSOCKS5(t, requests, func(conn net.Conn) {
+		req, err := http.ReadRequest(bufio.NewReader(conn))
+		if err != nil {
+			t.Errorf("no HTTP request through the proxy: %v", err)
+			return
+		}
+
+		fmt.Fprintf(conn, "HTTP/1.1 200 OK\r\nContent-Length: %d\r\n"+
+			"Connection: close\r\n\r\n%s", len(req.URL.Path),
+			req.URL.Path)
+	})
+
+	client, err := newHTTPClient("socks5h://"+addr, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.Get("http://prices.example.onion:8080/btc/usd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "/btc/usd" {
+		t.Fatalf("got %q through the proxy, want /btc/usd", body)
+	}
+
+	req := <-requests
+	if req.addrType != 0x03 || req.host != "prices.example.onion" ||
+		req.port != 8080 {
+
+		t.Fatalf("proxy was asked for type %d %s:%d, want the host "+
+			"name prices.example.onion:8080", req.addrType,
+			req.host, req.port)
+	}
+
+	_, err = newHTTPClient("http://127.0.0.1:8080", time.Second)
+	if err == nil {
+		t.Fatal("an HTTP proxy was accepted")
+	}
+}
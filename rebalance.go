@@ -0,0 +1,354 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/routing/route"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	// defaultMaxPayments is the number of payments lnd returns per call
+	// unless asked for more.
+	defaultMaxPayments = 100
+
+	// paymentsPageSize is the number of payments fetched per call while
+	// scanning the payment history.
+	paymentsPageSize = 1000
+
+	// maxPaymentDuration is how long a payment may take from its creation
+	// until its HTLCs resolve, lnd's default maximum CLTV expiry of 2016
+	// blocks.
+	maxPaymentDuration = 14 * 24 * time.Hour
+)
+
+// rebalance is a circular payment from our node back to itself, i.e. one
+// successful HTLC of it. Rebalancing tools may split a payment into several
+// parts, each of which can take different channels.
+type rebalance struct {
+	time time.Time
+
+	// outChannel is the first hop of the route, the channel whose local
+	// balance was moved. inChannel is the last hop, the channel which got
+	// the balance.
+	outChannel uint64
+	inChannel  uint64
+
+	// amount is the amount which arrived, fee what it cost on top.
+	amount lnwire.MilliSatoshi
+	fee    lnwire.MilliSatoshi
+}
+
+// paymentSucceeded returns true if one of the HTLCs of the payment
+// succeeded.
+func paymentSucceeded(p lndclient.Payment) bool {
+	for _, htlc := range p.Htlcs {
+		if htlc.Status == lnrpc.HTLCAttempt_SUCCEEDED {
+			return true
+		}
+	}
+
+	return false
+}
+
+// fetchPayments pages through the payment history and returns the completed
+// payments which have an HTLC that resolved between start and end. Only the
+// payments created from maxPaymentDuration before start are scanned, so the
+// time taken doesn't grow with the whole history of the node.
+func fetchPayments(ctx context.Context, client lndAPI,
+	start, end time.Time) ([]lndclient.Payment, error) {
+
+	req := lndclient.ListPaymentsRequest{
+		MaxPayments:       paymentsPageSize,
+		CreationDateStart: start.Add(-maxPaymentDuration),
+	}
+
+	var payments []lndclient.Payment
+	for {
+		resp, err := client.ListPayments(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range resp.Payments {
+			for _, htlc := range p.Htlcs {
+				t := time.Unix(0, htlc.ResolveTimeNs)
+				if !t.Before(start) && t.Before(end) {
+					payments = append(payments, p)
+					break
+				}
+			}
+		}
+
+		if len(resp.Payments) < int(req.MaxPayments) {
+			return payments, nil
+		}
+		req.Offset = resp.LastIndexOffset
+	}
+}
+
+// fetchRebalances returns the successful parts of the payments to our own
+// node which settled between start and end.
+func fetchRebalances(ctx context.Context, client lndAPI, self route.Vertex,
+	start, end time.Time) ([]rebalance, error) {
+
+	payments, err := fetchPayments(ctx, client, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	selfKey := hex.EncodeToString(self[:])
+
+	var rebalances []rebalance
+	for _, p := range payments {
+		for _, htlc := range p.Htlcs {
+			r, ok := htlcRebalance(htlc, selfKey)
+			if !ok || r.time.Before(start) || !r.time.Before(end) {
+				continue
+			}
+
+			rebalances = append(rebalances, r)
+		}
+	}
+
+	return rebalances, nil
+}
+
+// htlcRebalance returns the rebalance of a payment HTLC, false if it didn't
+// succeed or didn't end at our node.
+func htlcRebalance(htlc *lnrpc.HTLCAttempt, selfKey string) (rebalance,
+	bool) {
+
+	if htlc.Status != lnrpc.HTLCAttempt_SUCCEEDED {
+		return rebalance{}, false
+	}
+
+	hops := htlc.GetRoute().GetHops()
+	if len(hops) < 2 || hops[len(hops)-1].PubKey != selfKey {
+		return rebalance{}, false
+	}
+
+	r := htlc.Route
+	return rebalance{
+		time:       time.Unix(0, htlc.ResolveTimeNs),
+		outChannel: hops[0].ChanId,
+		inChannel:  hops[len(hops)-1].ChanId,
+		amount:     lnwire.MilliSatoshi(r.TotalAmtMsat - r.TotalFeesMsat),
+		fee:        lnwire.MilliSatoshi(r.TotalFeesMsat),
+	}, true
+}
+
+// fetchRebalanceFees returns the fees of the rebalances between start and
+// end per channel they refilled.
+func fetchRebalanceFees(ctx context.Context, client lndAPI,
+	start, end time.Time) (map[uint64]lnwire.MilliSatoshi, error) {
+
+	info, err := client.GetInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rebalances, err := fetchRebalances(
+		ctx, client, route.Vertex(info.IdentityPubkey), start, end,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	fees := make(map[uint64]lnwire.MilliSatoshi)
+	for _, r := range rebalances {
+		fees[r.inChannel] += r.fee
+	}
+
+	return fees, nil
+}
+
+// ppm returns the fee in parts per million of the amount.
+func ppm(fee, amount lnwire.MilliSatoshi) int64 {
+	if amount == 0 {
+		return 0
+	}
+
+	return int64(math.Round(float64(fee) * 1e6 / float64(amount)))
+}
+
+// listRebalances prints the rebalances in the --since and --until range,
+// followed by their totals per channel.
+func listRebalances(ctx *cli.Context) error {
+	ctxb := ctx.Context
+	client, err := getClient(ctxb, ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to LND: %w", err)
+	}
+	defer client.Close()
+
+	chanFmt, err := getChanFormat(ctx)
+	if err != nil {
+		return err
+	}
+
+	unit, err := getAmountUnit(ctx)
+	if err != nil {
+		return err
+	}
+
+	loc, err := time.LoadLocation(ctx.String("tz"))
+	if err != nil {
+		return fmt.Errorf("invalid --tz: %w", err)
+	}
+	now := client.Now()
+
+	start, err := parseTimeSpec(ctx.String("since"), now, loc)
+	if err != nil {
+		return fmt.Errorf("invalid --since: %w", err)
+	}
+
+	end := now
+	if ctx.IsSet("until") {
+		end, err = parseTimeSpec(ctx.String("until"), now, loc)
+		if err != nil {
+			return fmt.Errorf("invalid --until: %w", err)
+		}
+	}
+
+	channels := make(map[uint64]bool)
+	ids, err := resolveChanIDs(ctxb, client.Client, ctx.StringSlice("channel"))
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		channels[id] = true
+	}
+
+	info, err := client.Client.GetInfo(ctxb)
+	if err != nil {
+		return err
+	}
+
+	all, err := fetchRebalances(
+		ctxb, client.Client, route.Vertex(info.IdentityPubkey), start, end,
+	)
+	if err != nil {
+		return err
+	}
+
+	var rebalances []rebalance
+	for _, r := range all {
+		if len(channels) > 0 && !channels[r.outChannel] &&
+			!channels[r.inChannel] {
+
+			continue
+		}
+		rebalances = append(rebalances, r)
+	}
+
+	sort.SliceStable(rebalances, func(i, j int) bool {
+		return rebalances[i].time.After(rebalances[j].time)
+	})
+
+	printRebalances(rebalances, loc, &rowFormat{chanFmt: chanFmt, unit: unit})
+
+	return nil
+}
+
+// rebalanceTotals sums up the rebalances of a channel.
+type rebalanceTotals struct {
+	chanID uint64
+	count  int
+
+	// out is the amount moved out of the channel, in the amount moved
+	// into it. The fees are charged to the channel which was refilled.
+	out lnwire.MilliSatoshi
+	in  lnwire.MilliSatoshi
+	fee lnwire.MilliSatoshi
+}
+
+// printRebalances prints the rebalances and their totals per channel.
+func printRebalances(rebalances []rebalance, loc *time.Location,
+	f *rowFormat) {
+
+	table := newTextTable(
+		"Num", "Time", "Out Channel", "In Channel", "Amount", "Fee",
+		"PPM",
+	)
+
+	totals := make(map[uint64]*rebalanceTotals)
+	channel := func(chanID uint64) *rebalanceTotals {
+		t, ok := totals[chanID]
+		if !ok {
+			t = &rebalanceTotals{chanID: chanID}
+			totals[chanID] = t
+		}
+
+		return t
+	}
+
+	var amount, fee lnwire.MilliSatoshi
+	for i, r := range rebalances {
+		table.addRow(
+			fmt.Sprint(i+1),
+			r.time.In(loc).Format(time.RFC3339),
+			f.chanFmt.format(r.outChannel),
+			f.chanFmt.format(r.inChannel),
+			f.unit.format(r.amount),
+			f.unit.format(r.fee),
+			fmt.Sprint(ppm(r.fee, r.amount)),
+		)
+
+		out := channel(r.outChannel)
+		out.count++
+		out.out += r.amount
+
+		in := channel(r.inChannel)
+		in.count++
+		in.in += r.amount
+		in.fee += r.fee
+
+		amount += r.amount
+		fee += r.fee
+	}
+	table.setFooter(
+		fmt.Sprint(len(rebalances)), "", "", "",
+		f.unit.format(amount), f.unit.format(fee),
+		fmt.Sprint(ppm(fee, amount)),
+	)
+	table.print()
+
+	byChannel := make([]*rebalanceTotals, 0, len(totals))
+	for _, t := range totals {
+		byChannel = append(byChannel, t)
+	}
+	sort.Slice(byChannel, func(i, j int) bool {
+		if byChannel[i].fee != byChannel[j].fee {
+			return byChannel[i].fee > byChannel[j].fee
+		}
+
+		return byChannel[i].chanID > byChannel[j].chanID
+	})
+
+	fmt.Println()
+
+	table = newTextTable(
+		"Channel ID", "Rebalances", "Moved Out", "Moved In", "Fee Paid",
+		"PPM",
+	)
+	for _, t := range byChannel {
+		table.addRow(
+			f.chanFmt.format(t.chanID),
+			fmt.Sprint(t.count),
+			f.unit.format(t.out),
+			f.unit.format(t.in),
+			f.unit.format(t.fee),
+			fmt.Sprint(ppm(t.fee, t.in)),
+		)
+	}
+	table.print()
+}
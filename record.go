@@ -0,0 +1,522 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/routing/route"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// The parts of a recording which --redact can replace.
+const (
+	redactPubkeys    = "pubkeys"
+	redactAliases    = "aliases"
+	redactChanPoints = "chanpoints"
+	redactAddresses  = "addresses"
+	redactAll        = "all"
+)
+
+// redactor replaces identifying data of a recording with stand-ins. The
+// stand-ins are derived from a random salt, so they are consistent within a
+// recording but can't be traced back by hashing the public graph.
+type redactor struct {
+	pubkeys    bool
+	aliases    bool
+	chanPoints bool
+	addresses  bool
+
+	salt [32]byte
+
+	mu   sync.Mutex
+	keys map[route.Vertex]route.Vertex
+
+	// chanIDs maps short channel ids to their stand-ins, usedIDs holds
+	// the stand-ins handed out, so no two channels get the same.
+	chanIDs map[uint64]uint64
+	usedIDs map[uint64]bool
+}
+
+// newRedactor returns a redactor for the comma separated parts listed in
+// --redact.
+func newRedactor(parts []string) (*redactor, error) {
+	r := &redactor{
+		keys:    make(map[route.Vertex]route.Vertex),
+		chanIDs: make(map[uint64]uint64),
+		usedIDs: make(map[uint64]bool),
+	}
+	if _, err := rand.Read(r.salt[:]); err != nil {
+		return nil, err
+	}
+
+	for _, part := range parts {
+		for _, p := range strings.Split(part, ",") {
+			switch strings.TrimSpace(p) {
+			case redactPubkeys:
+				r.pubkeys = true
+			case redactAliases:
+				r.aliases = true
+			case redactChanPoints:
+				r.chanPoints = true
+			case redactAddresses:
+				r.addresses = true
+			case redactAll:
+				r.pubkeys = true
+				r.aliases = true
+				r.chanPoints = true
+				r.addresses = true
+			default:
+				return nil, fmt.Errorf("invalid --redact %q, "+
+					"should be one of %s, %s, %s, %s or "+
+					"%s", p, redactPubkeys, redactAliases,
+					redactChanPoints, redactAddresses,
+					redactAll)
+			}
+		}
+	}
+
+	return r, nil
+}
+
+// hash returns the salted hash of data.
+func (r *redactor) hash(data []byte) [32]byte {
+	return sha256.Sum256(append(r.salt[:], data...))
+}
+
+// pubkey returns the stand-in for a node's public key.
+func (r *redactor) pubkey(key route.Vertex) route.Vertex {
+	if !r.pubkeys {
+		return key
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	fake, ok := r.keys[key]
+	if !ok {
+		h := r.hash(key[:])
+		fake[0] = 0x02
+		copy(fake[1:], h[:])
+		r.keys[key] = fake
+	}
+
+	return fake
+}
+
+// alias returns the stand-in for the alias of node.
+func (r *redactor) alias(alias string, node route.Vertex) string {
+	if !r.aliases {
+		return alias
+	}
+
+	h := r.hash(node[:])
+	return "node-" + hex.EncodeToString(h[:3])
+}
+
+// chanPoint returns the stand-in for a txid:index channel point.
+func (r *redactor) chanPoint(chanPoint string) string {
+	if !r.chanPoints || chanPoint == "" {
+		return chanPoint
+	}
+
+	txid, index, _ := strings.Cut(chanPoint, ":")
+	return r.txid(txid) + ":" + index
+}
+
+// chanID returns the stand-in for a short channel id, which would point
+// at the funding output otherwise. The block height is kept, since the ages
+// of channels are derived from it, the transaction index and output are
+// replaced. The same id always gets the same stand-in, so forwards, events
+// and payments still join to their channels.
+func (r *redactor) chanID(chanID uint64) uint64 {
+	if !r.chanPoints || chanID == 0 {
+		return chanID
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if fake, ok := r.chanIDs[chanID]; ok {
+		return fake
+	}
+
+	// Another try is salted with a counter if the stand-in is taken.
+	var data [12]byte
+	binary.BigEndian.PutUint64(data[:8], chanID)
+	for try := uint32(0); ; try++ {
+		binary.BigEndian.PutUint32(data[8:], try)
+		h := r.hash(data[:])
+
+		fake := lnwire.ShortChannelID{
+			BlockHeight: lnwire.NewShortChanIDFromInt(
+				chanID,
+			).BlockHeight,
+			TxIndex:    binary.BigEndian.Uint32(h[:4]) % 4000,
+			TxPosition: uint16(h[4] % 4),
+		}.ToUint64()
+		if r.usedIDs[fake] {
+			continue
+		}
+
+		r.chanIDs[chanID] = fake
+		r.usedIDs[fake] = true
+
+		return fake
+	}
+}
+
+// txid returns the stand-in for a transaction id. It matches the stand-in
+// of the channel points of the transaction.
+func (r *redactor) txid(txid string) string {
+	if !r.chanPoints || txid == "" {
+		return txid
+	}
+
+	h := r.hash([]byte(txid))
+	return hex.EncodeToString(h[:])
+}
+
+// txHash returns the stand-in for a transaction hash in its internal byte
+// order. It matches the stand-in txid returns for its string form.
+func (r *redactor) txHash(hash [32]byte) [32]byte {
+	if !r.chanPoints || hash == ([32]byte{}) {
+		return hash
+	}
+
+	// The string form of a hash is its bytes reversed.
+	var reversed [32]byte
+	for i := range hash {
+		reversed[i] = hash[len(hash)-1-i]
+	}
+	fake := r.hash([]byte(hex.EncodeToString(reversed[:])))
+	for i := range fake {
+		reversed[i] = fake[len(fake)-1-i]
+	}
+
+	return reversed
+}
+
+// pendingChannels replaces the nodes, channel points and closing
+// transactions of the pending channels.
+func (r *redactor) pendingChannels(p *lndclient.PendingChannels) {
+	channel := func(c *lndclient.PendingChannel) {
+		c.PubKeyBytes = r.pubkey(c.PubKeyBytes)
+		if c.ChannelPoint != nil {
+			point := *c.ChannelPoint
+			point.Hash = r.txHash(point.Hash)
+			c.ChannelPoint = &point
+		}
+	}
+
+	for i := range p.PendingOpen {
+		channel(&p.PendingOpen[i])
+	}
+	for i := range p.PendingForceClose {
+		c := &p.PendingForceClose[i]
+		channel(&c.PendingChannel)
+		c.CloseTxid = r.txHash(c.CloseTxid)
+	}
+	for i := range p.WaitingClose {
+		c := &p.WaitingClose[i]
+		channel(&c.PendingChannel)
+		c.LocalTxid = r.txHash(c.LocalTxid)
+		c.RemoteTxid = r.txHash(c.RemoteTxid)
+		c.RemotePending = r.txHash(c.RemotePending)
+	}
+}
+
+// transaction replaces the ids, label and scripts of a wallet transaction.
+// Its amounts and fee are kept.
+func (r *redactor) transaction(tx *lndclient.Transaction) {
+	tx.TxHash = r.txid(tx.TxHash)
+	if r.chanPoints {
+		tx.Label = ""
+	}
+
+	if tx.Tx == nil || (!r.chanPoints && !r.addresses) {
+		return
+	}
+
+	raw := *tx.Tx
+	raw.TxIn = nil
+	raw.TxOut = make([]*wire.TxOut, len(tx.Tx.TxOut))
+	for i, out := range tx.Tx.TxOut {
+		raw.TxOut[i] = &wire.TxOut{Value: out.Value}
+	}
+	tx.Tx = &raw
+}
+
+// payment replaces the nodes and channels of the routes of a payment and
+// drops its payment request, which names the destination.
+func (r *redactor) payment(p *lndclient.Payment) {
+	for _, htlc := range p.Htlcs {
+		for _, hop := range htlc.GetRoute().GetHops() {
+			hop.ChanId = r.chanID(hop.ChanId)
+		}
+	}
+
+	if !r.pubkeys {
+		return
+	}
+
+	p.PaymentRequest = ""
+	for _, htlc := range p.Htlcs {
+		for _, hop := range htlc.GetRoute().GetHops() {
+			key, err := route.NewVertexFromStr(hop.PubKey)
+			if err != nil {
+				continue
+			}
+
+			fake := r.pubkey(key)
+			hop.PubKey = hex.EncodeToString(fake[:])
+		}
+	}
+}
+
+// uris drops node addresses, which also contain our public key.
+func (r *redactor) uris(uris []string) []string {
+	if !r.addresses && !r.pubkeys {
+		return uris
+	}
+
+	return nil
+}
+
+func recordFixtures(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		return fmt.Errorf("expected the fixture directory as the only " +
+			"argument")
+	}
+	dir := cleanAndExpandPath(ctx.Args().First())
+
+	redact, err := newRedactor(ctx.StringSlice("redact"))
+	if err != nil {
+		return err
+	}
+
+	ctxb := ctx.Context
+	client, err := getClient(ctxb, ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to LND: %w", err)
+	}
+	defer client.Close()
+
+	now := client.Now().UTC()
+	start := now.Add(-time.Hour * 24 * time.Duration(ctx.Int("days")))
+
+	var (
+		info         *lndclient.Info
+		channels     []lndclient.ChannelInfo
+		forwards     []lndclient.ForwardingEvent
+		closed       []lndclient.ClosedChannel
+		transactions []lndclient.Transaction
+		payments     []lndclient.Payment
+		pending      *lndclient.PendingChannels
+		wallet       *lndclient.WalletBalance
+	)
+	g, gctx := errgroup.WithContext(ctxb)
+	g.Go(func() error {
+		var err error
+		info, err = client.Client.GetInfo(gctx)
+		return err
+	})
+	g.Go(func() error {
+		var err error
+		channels, err = client.Client.ListChannels(gctx, false, false)
+		return err
+	})
+	g.Go(func() error {
+		var err error
+		forwards, err = fetchForwards(gctx, client.Client, start, now)
+		return err
+	})
+	g.Go(func() error {
+		var err error
+		closed, err = client.Client.ClosedChannels(gctx)
+		return err
+	})
+	g.Go(func() error {
+		var err error
+		transactions, err = client.Client.ListTransactions(gctx, 0, -1)
+		return err
+	})
+	g.Go(func() error {
+		var err error
+		payments, err = fetchPayments(gctx, client.Client, start, now)
+		return err
+	})
+	g.Go(func() error {
+		var err error
+		pending, err = client.Client.PendingChannels(gctx)
+		return err
+	})
+	g.Go(func() error {
+		var err error
+		wallet, err = client.Client.WalletBalance(gctx)
+		return err
+	})
+	if err := g.Wait(); err != nil {
+		return err
+	}
+
+	// The graph lookups depend on the channels, each of them is
+	// independent though. Private peers and channels are not in the
+	// graph, which is fine.
+	var (
+		mu    sync.Mutex
+		nodes = make(map[string]*lndclient.NodeInfo)
+		edges = make(map[uint64]*lndclient.ChannelEdge)
+	)
+	g, gctx = errgroup.WithContext(ctxb)
+	for _, c := range channels {
+		g.Go(func() error {
+			node, err := client.Client.GetNodeInfo(
+				gctx, c.PubKeyBytes, false,
+			)
+			switch {
+			case status.Code(err) == codes.NotFound:
+				return nil
+
+			case err != nil:
+				return err
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if node.Node != nil {
+				n := *node.Node
+				n.PubKey = redact.pubkey(n.PubKey)
+				n.Alias = redact.alias(n.Alias, c.PubKeyBytes)
+				n.Addresses = redact.uris(n.Addresses)
+				node.Node = &n
+			}
+			key := redact.pubkey(c.PubKeyBytes)
+			nodes[hex.EncodeToString(key[:])] = node
+
+			return nil
+		})
+		g.Go(func() error {
+			edge, err := client.Client.GetChanInfo(gctx, c.ChannelID)
+			switch {
+			case status.Code(err) == codes.NotFound:
+				return nil
+
+			case err != nil:
+				return err
+			}
+
+			edge.ChannelID = redact.chanID(edge.ChannelID)
+			edge.Node1 = redact.pubkey(edge.Node1)
+			edge.Node2 = redact.pubkey(edge.Node2)
+			edge.ChannelPoint = redact.chanPoint(edge.ChannelPoint)
+
+			mu.Lock()
+			edges[redact.chanID(c.ChannelID)] = edge
+			mu.Unlock()
+
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return err
+	}
+
+	// The HTLC events watch htlcs recorded in the window are replayed as
+	// the event stream of the router.
+	store := openNodeStore(ctx, route.Vertex(info.IdentityPubkey))
+	recorded, err := readRecords[htlcEvent](store, htlcEventsFile)
+	if err != nil {
+		return err
+	}
+	var htlcEvents []htlcEvent
+	for _, e := range recorded {
+		if !e.Time.Before(start) {
+			e.ChannelIn = redact.chanID(e.ChannelIn)
+			e.ChannelOut = redact.chanID(e.ChannelOut)
+			htlcEvents = append(htlcEvents, e)
+		}
+	}
+
+	info.Alias = redact.alias(info.Alias, info.IdentityPubkey)
+	info.IdentityPubkey = redact.pubkey(info.IdentityPubkey)
+	info.Uris = redact.uris(info.Uris)
+
+	for i := range channels {
+		channels[i].ChannelID = redact.chanID(channels[i].ChannelID)
+		for j := range channels[i].PendingHtlcs {
+			h := &channels[i].PendingHtlcs[j]
+			h.ForwardingChannel = redact.chanID(h.ForwardingChannel)
+		}
+		channels[i].PubKeyBytes = redact.pubkey(channels[i].PubKeyBytes)
+		channels[i].ChannelPoint = redact.chanPoint(
+			channels[i].ChannelPoint,
+		)
+	}
+
+	for i := range forwards {
+		forwards[i].ChannelIn = redact.chanID(forwards[i].ChannelIn)
+		forwards[i].ChannelOut = redact.chanID(forwards[i].ChannelOut)
+	}
+	for i := range closed {
+		closed[i].ChannelID = redact.chanID(closed[i].ChannelID)
+		closed[i].PubKeyBytes = redact.pubkey(closed[i].PubKeyBytes)
+		closed[i].ChannelPoint = redact.chanPoint(closed[i].ChannelPoint)
+		closed[i].ClosingTxHash = redact.txid(closed[i].ClosingTxHash)
+	}
+	for i := range transactions {
+		redact.transaction(&transactions[i])
+	}
+	for i := range payments {
+		redact.payment(&payments[i])
+	}
+	redact.pendingChannels(pending)
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("unable to create fixture directory: %w", err)
+	}
+
+	files := []struct {
+		name string
+		v    interface{}
+	}{
+		{recordingFixture, fixtureRecording{Time: now}},
+		{getInfoFixture, info},
+		{listChannelsFixture, channels},
+		{forwardingHistoryFixture, forwards},
+		{nodeInfoFixture, nodes},
+		{chanInfoFixture, edges},
+		{closedChannelsFixture, closed},
+		{transactionsFixture, transactions},
+		{paymentsFixture, payments},
+		{pendingChannelsFixture, pending},
+		{walletBalanceFixture, wallet},
+		{htlcEventsFixture, htlcEvents},
+	}
+	for _, file := range files {
+		if err := writeFixture(dir, file.name, file.v); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Recorded %d channels, %d closed channels, %d forwards, "+
+		"%d payments, %d transactions, %d HTLC events and %d nodes "+
+		"to %s\n", len(channels), len(closed), len(forwards),
+		len(payments), len(transactions), len(htlcEvents), len(nodes),
+		dir)
+
+	return nil
+}
@@ -0,0 +1,535 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/routing/route"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// blockInterval is the average time between two blocks, used to turn
+	// block heights into ages.
+	blockInterval = 10 * time.Minute
+
+	// historyMargin is added to the start of histories which are
+	// estimated from block heights, which only approximate time.
+	historyMargin = 90 * 24 * time.Hour
+
+	// daysPerYear annualizes returns.
+	daysPerYear = 365
+)
+
+// channelReturn is the profitability of a channel over its lifetime.
+type channelReturn struct {
+	chanID uint64
+	peer   route.Vertex
+	alias  string
+	closed bool
+
+	// capital is what we have locked in the channel: the capacity if we
+	// opened it, our balance otherwise.
+	capital btcutil.Amount
+
+	// age is the time from the funding transaction to the closing
+	// transaction or to now, zero if it isn't known.
+	age time.Duration
+
+	// earned are the routing fees of the forwards which left through the
+	// channel, rebalanceFee the fees of the rebalances which refilled it.
+	earned       lnwire.MilliSatoshi
+	rebalanceFee lnwire.MilliSatoshi
+
+	// openFee and closeFee are the on-chain fees we paid. Only the opener
+	// pays them.
+	openFee  btcutil.Amount
+	closeFee btcutil.Amount
+}
+
+// net returns the fees earned minus all costs, in millisatoshis.
+func (r *channelReturn) net() int64 {
+	return int64(r.earned) - int64(r.rebalanceFee) -
+		int64(sat(r.openFee)) - int64(sat(r.closeFee))
+}
+
+// capitalYears returns the capital multiplied by the age in years.
+func (r *channelReturn) capitalYears() float64 {
+	years := r.age.Hours() / 24 / daysPerYear
+	return float64(sat(r.capital)) * years
+}
+
+// annualROI returns the net return per year in percent of the capital,
+// false if the capital or the age isn't known.
+func (r *channelReturn) annualROI() (float64, bool) {
+	cy := r.capitalYears()
+	if cy <= 0 {
+		return 0, false
+	}
+
+	return float64(r.net()) / cy * 100, true
+}
+
+// fundingTxid returns the funding transaction id of a txid:index channel
+// point.
+func fundingTxid(chanPoint string) string {
+	txid, _, _ := strings.Cut(chanPoint, ":")
+	return txid
+}
+
+// blockAge returns the time between two block heights.
+func blockAge(from, to uint32) time.Duration {
+	if to <= from {
+		return 0
+	}
+
+	return time.Duration(to-from) * blockInterval
+}
+
+// txHeight returns the height a wallet transaction confirmed at, zero if it
+// is unconfirmed.
+func txHeight(tx lndclient.Transaction, best uint32) uint32 {
+	if tx.Confirmations <= 0 || uint32(tx.Confirmations) > best+1 {
+		return 0
+	}
+
+	return best - uint32(tx.Confirmations) + 1
+}
+
+// closeTxFee returns the fee of a closing transaction: whatever of the
+// capacity isn't paid out by it.
+func closeTxFee(tx lndclient.Transaction, capacity btcutil.Amount) (
+	btcutil.Amount, bool) {
+
+	if tx.Tx == nil {
+		return 0, false
+	}
+
+	var out btcutil.Amount
+	for _, o := range tx.Tx.TxOut {
+		out += btcutil.Amount(o.Value)
+	}
+	if out > capacity {
+		return 0, false
+	}
+
+	return capacity - out, true
+}
+
+// closedWithLife returns the closed channels apart from those which never
+// confirmed or were abandoned, which had no life.
+func closedWithLife(closed []lndclient.ClosedChannel) []lndclient.ClosedChannel {
+	var channels []lndclient.ClosedChannel
+	for _, c := range closed {
+		switch c.CloseType {
+		case lndclient.CloseTypeFundingCancelled,
+			lndclient.CloseTypeAbandoned:
+
+			continue
+		}
+		channels = append(channels, c)
+	}
+
+	return channels
+}
+
+// channelTxs matches the wallet transactions with the funding and closing
+// transactions of the channels by their channel points and closing txids.
+type channelTxs struct {
+	wallet map[string]lndclient.Transaction
+	closed []lndclient.ClosedChannel
+
+	// opened is the number of channels we opened per funding txid. A
+	// funding transaction may open several channels at once.
+	opened map[string]int
+}
+
+// newChannelTxs indexes the wallet transactions of the open and closed
+// channels.
+func newChannelTxs(channels []lndclient.ChannelInfo,
+	closed []lndclient.ClosedChannel,
+	txs []lndclient.Transaction) *channelTxs {
+
+	m := &channelTxs{
+		wallet: make(map[string]lndclient.Transaction, len(txs)),
+		closed: closed,
+		opened: make(map[string]int),
+	}
+	for _, tx := range txs {
+		m.wallet[tx.TxHash] = tx
+	}
+
+	for _, c := range channels {
+		if c.Initiator {
+			m.opened[fundingTxid(c.ChannelPoint)]++
+		}
+	}
+	for _, c := range closed {
+		if c.OpenInitiator == lndclient.InitiatorLocal {
+			m.opened[fundingTxid(c.ChannelPoint)]++
+		}
+	}
+
+	return m
+}
+
+// openFee returns the share of a channel in the fee of its funding
+// transaction, zero if we didn't open it.
+func (m *channelTxs) openFee(chanPoint string) btcutil.Amount {
+	txid := fundingTxid(chanPoint)
+	tx, ok := m.wallet[txid]
+	if !ok || m.opened[txid] == 0 {
+		return 0
+	}
+
+	return tx.Fee / btcutil.Amount(m.opened[txid])
+}
+
+// closeTx returns the closing transaction of a channel, false if it didn't
+// pay to our wallet.
+func (m *channelTxs) closeTx(c lndclient.ClosedChannel) (
+	lndclient.Transaction, bool) {
+
+	tx, ok := m.wallet[c.ClosingTxHash]
+	return tx, ok
+}
+
+// closeFee returns the fee of the closing transaction of a channel if we
+// paid it, which is the case if we opened the channel.
+func (m *channelTxs) closeFee(c lndclient.ClosedChannel) (btcutil.Amount,
+	bool) {
+
+	tx, ok := m.closeTx(c)
+	if !ok || c.OpenInitiator != lndclient.InitiatorLocal {
+		return 0, false
+	}
+
+	return closeTxFee(tx, c.Capacity)
+}
+
+// chainFee is an on-chain fee paid for the channels.
+type chainFee struct {
+	time time.Time
+	fee  btcutil.Amount
+}
+
+// chainFees returns the fees of the funding transactions of the channels
+// we opened and of their closing transactions, once per transaction.
+// Other wallet transactions, like withdrawals or sweeps, aren't included.
+func (m *channelTxs) chainFees() []chainFee {
+	var fees []chainFee
+	for txid := range m.opened {
+		if tx, ok := m.wallet[txid]; ok && tx.Fee > 0 {
+			fees = append(fees, chainFee{
+				time: tx.Timestamp,
+				fee:  tx.Fee,
+			})
+		}
+	}
+
+	for _, c := range m.closed {
+		if fee, ok := m.closeFee(c); ok && fee > 0 {
+			tx, _ := m.closeTx(c)
+			fees = append(fees, chainFee{time: tx.Timestamp, fee: fee})
+		}
+	}
+
+	return fees
+}
+
+// reportROI prints the profitability of every open and closed channel.
+func reportROI(ctx *cli.Context) error {
+	ctxb := ctx.Context
+	client, err := getClient(ctxb, ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to LND: %w", err)
+	}
+	defer client.Close()
+
+	chanFmt, err := getChanFormat(ctx)
+	if err != nil {
+		return err
+	}
+
+	unit, err := getAmountUnit(ctx)
+	if err != nil {
+		return err
+	}
+
+	returns, err := channelReturns(ctxb, client.Client, client.Now())
+	if err != nil {
+		return err
+	}
+
+	if ctx.Bool("alias") {
+		peers := make([]route.Vertex, 0, len(returns))
+		for _, r := range returns {
+			peers = append(peers, r.peer)
+		}
+
+		aliases, err := lookupAliases(ctxb, client.Client, peers)
+		if err != nil {
+			return err
+		}
+		for i := range returns {
+			returns[i].alias = aliases[returns[i].peer]
+		}
+	}
+
+	// The best channels first, those whose return can't be known last.
+	sort.SliceStable(returns, func(i, j int) bool {
+		ri, oki := returns[i].annualROI()
+		rj, okj := returns[j].annualROI()
+		if oki != okj {
+			return oki
+		}
+
+		return ri > rj
+	})
+
+	printReturns(returns, &rowFormat{chanFmt: chanFmt, unit: unit})
+
+	return nil
+}
+
+// channelReturns computes the profitability of every open and closed
+// channel from the forwarding, payment and on-chain histories.
+//
+// Fees are credited to the outgoing channel of a forward, since that is the
+// liquidity which was sold. Rebalance fees are charged to the channel which
+// was refilled. The on-chain fees are taken from the wallet transactions:
+// the fee of a funding transaction is split between the channels it opened,
+// the fee of a closing transaction is what it didn't pay out of the
+// capacity. Closing transactions which didn't pay to our wallet aren't
+// known, so neither is the close fee and age of such channels. Open
+// channels are aged up to now.
+func channelReturns(ctx context.Context, client lndAPI,
+	now time.Time) ([]channelReturn, error) {
+
+	var (
+		info     *lndclient.Info
+		channels []lndclient.ChannelInfo
+		closed   []lndclient.ClosedChannel
+		txs      []lndclient.Transaction
+	)
+	g, gctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		var err error
+		info, err = client.GetInfo(gctx)
+		return err
+	})
+	g.Go(func() error {
+		var err error
+		channels, err = client.ListChannels(gctx, false, false)
+		return err
+	})
+	g.Go(func() error {
+		var err error
+		closed, err = client.ClosedChannels(gctx)
+		return err
+	})
+	g.Go(func() error {
+		var err error
+		txs, err = client.ListTransactions(gctx, 0, -1)
+		return err
+	})
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	closedChannels := closedWithLife(closed)
+
+	best := info.BlockHeight
+
+	// The histories need to go back to the oldest channel.
+	oldest := best
+	for _, c := range channels {
+		oldest = min(oldest, lnwire.NewShortChanIDFromInt(c.ChannelID).BlockHeight)
+	}
+	for _, c := range closedChannels {
+		oldest = min(oldest, lnwire.NewShortChanIDFromInt(c.ChannelID).BlockHeight)
+	}
+	start := now.Add(-blockAge(oldest, best) - historyMargin)
+
+	var (
+		forwards   []lndclient.ForwardingEvent
+		rebalances []rebalance
+	)
+	g, gctx = errgroup.WithContext(ctx)
+	g.Go(func() error {
+		var err error
+		forwards, err = fetchForwards(gctx, client, start, now)
+		return err
+	})
+	g.Go(func() error {
+		var err error
+		rebalances, err = fetchRebalances(
+			gctx, client, route.Vertex(info.IdentityPubkey), start,
+			now,
+		)
+		return err
+	})
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	earned := make(map[uint64]lnwire.MilliSatoshi)
+	for _, e := range forwards {
+		earned[e.ChannelOut] += e.FeeMsat
+	}
+
+	rebalanceFees := make(map[uint64]lnwire.MilliSatoshi)
+	for _, r := range rebalances {
+		rebalanceFees[r.inChannel] += r.fee
+	}
+
+	chanTxs := newChannelTxs(channels, closedChannels, txs)
+
+	returns := make([]channelReturn, 0, len(channels)+len(closedChannels))
+	for _, c := range channels {
+		r := channelReturn{
+			chanID:       c.ChannelID,
+			peer:         c.PubKeyBytes,
+			capital:      c.LocalBalance,
+			earned:       earned[c.ChannelID],
+			rebalanceFee: rebalanceFees[c.ChannelID],
+			age: blockAge(
+				lnwire.NewShortChanIDFromInt(c.ChannelID).BlockHeight,
+				best,
+			),
+		}
+		if c.Initiator {
+			r.capital = c.Capacity
+			r.openFee = chanTxs.openFee(c.ChannelPoint)
+		}
+
+		returns = append(returns, r)
+	}
+
+	for _, c := range closedChannels {
+		r := channelReturn{
+			chanID:       c.ChannelID,
+			peer:         c.PubKeyBytes,
+			closed:       true,
+			capital:      c.SettledBalance,
+			earned:       earned[c.ChannelID],
+			rebalanceFee: rebalanceFees[c.ChannelID],
+		}
+		if c.OpenInitiator == lndclient.InitiatorLocal {
+			r.capital = c.Capacity
+			r.openFee = chanTxs.openFee(c.ChannelPoint)
+		}
+
+		if tx, ok := chanTxs.closeTx(c); ok {
+			if height := txHeight(tx, best); height > 0 {
+				r.age = blockAge(
+					lnwire.NewShortChanIDFromInt(
+						c.ChannelID,
+					).BlockHeight, height,
+				)
+			}
+		}
+		if fee, ok := chanTxs.closeFee(c); ok {
+			r.closeFee = fee
+		}
+
+		returns = append(returns, r)
+	}
+
+	return returns, nil
+}
+
+// printReturns prints the profitability of the channels followed by their
+// totals.
+func printReturns(returns []channelReturn, f *rowFormat) {
+	table := newTextTable(
+		"Num", "Channel ID", "Peer", "State", "Age", "Capital",
+		"Earned", "Open Fee", "Close Fee", "Rebal Fee", "Net",
+		"ROI/yr",
+	)
+	table.alignLeft(2, 3)
+
+	formatROI := func(roi float64, ok bool) string {
+		if !ok {
+			return "-"
+		}
+
+		return fmt.Sprintf("%.2f%%", roi)
+	}
+
+	formatAge := func(age time.Duration) string {
+		if age == 0 {
+			return "-"
+		}
+
+		return fmt.Sprintf("%dd", int64(math.Round(age.Hours()/24)))
+	}
+
+	var (
+		total        channelReturn
+		capitalYears float64
+		knownNet     int64
+	)
+	for i, r := range returns {
+		peer := r.alias
+		if peer == "" {
+			peer = hex.EncodeToString(r.peer[:4])
+		}
+
+		state := "open"
+		if r.closed {
+			state = "closed"
+		}
+
+		roi, ok := r.annualROI()
+		table.addRow(
+			fmt.Sprint(i+1),
+			f.chanFmt.format(r.chanID),
+			truncate(peer, 10),
+			state,
+			formatAge(r.age),
+			f.unit.format(sat(r.capital)),
+			f.unit.format(r.earned),
+			f.unit.format(sat(r.openFee)),
+			f.unit.format(sat(r.closeFee)),
+			f.unit.format(r.rebalanceFee),
+			f.unit.formatSigned(r.net()),
+			formatROI(roi, ok),
+		)
+
+		total.capital += r.capital
+		total.earned += r.earned
+		total.openFee += r.openFee
+		total.closeFee += r.closeFee
+		total.rebalanceFee += r.rebalanceFee
+		if ok {
+			capitalYears += r.capitalYears()
+			knownNet += r.net()
+		}
+	}
+
+	// The total return is weighted by how much capital was locked for how
+	// long, which is only known for some channels.
+	totalROI := float64(knownNet) / capitalYears * 100
+	table.setFooter(
+		fmt.Sprint(len(returns)), "", "", "", "",
+		f.unit.format(sat(total.capital)),
+		f.unit.format(total.earned),
+		f.unit.format(sat(total.openFee)),
+		f.unit.format(sat(total.closeFee)),
+		f.unit.format(total.rebalanceFee),
+		f.unit.formatSigned(total.net()),
+		formatROI(totalROI, capitalYears > 0),
+	)
+
+	table.print()
+}
@@ -0,0 +1,395 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/lnrpc/routerrpc"
+	"github.com/lightningnetwork/lnd/routing/route"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultRPCTimeout  = 30 * time.Second
+	defaultRPCRetries  = 2
+	defaultRPCParallel = 4
+
+	// initialRetryBackoff is the wait before the first retry, it doubles
+	// with every further attempt up to maxRetryBackoff.
+	initialRetryBackoff = 500 * time.Millisecond
+	maxRetryBackoff     = 5 * time.Second
+)
+
+// rpcStats collects the time spent in calls to lnd for --timings. It is
+// shared by all clients of a single lnb run.
+var rpcStats = newRPCTimings()
+
+// retryPolicy describes how often an RPC to lnd is retried.
+type retryPolicy struct {
+	// retries is the number of attempts made after the first one failed
+	// with a transient error.
+	retries int
+}
+
+// rpcError is returned once an RPC has failed for good. It records which
+// call failed and how many attempts were made.
+type rpcError struct {
+	method   string
+	attempts int
+	err      error
+}
+
+// Error returns the error message, mentioning the attempts only if the call
+// was actually retried.
+func (e *rpcError) Error() string {
+	if e.attempts == 1 {
+		return fmt.Sprintf("client.%s failed: %v", e.method, e.err)
+	}
+
+	return fmt.Sprintf("client.%s failed after %d attempts: %v", e.method,
+		e.attempts, e.err)
+}
+
+// Unwrap returns the error of the last attempt.
+func (e *rpcError) Unwrap() error {
+	return e.err
+}
+
+// isTransient returns true if err is a gRPC error worth retrying, i.e. lnd
+// was unreachable or didn't answer in time.
+func isTransient(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	default:
+		return false
+	}
+}
+
+// rpcTiming is the time spent in one kind of RPC.
+type rpcTiming struct {
+	calls int
+	total time.Duration
+	max   time.Duration
+}
+
+// rpcTimings records how long each kind of RPC took. It is safe for
+// concurrent use.
+type rpcTimings struct {
+	mu      sync.Mutex
+	start   time.Time
+	methods map[string]*rpcTiming
+}
+
+// newRPCTimings returns an empty set of timings.
+func newRPCTimings() *rpcTimings {
+	return &rpcTimings{
+		start:   time.Now(),
+		methods: make(map[string]*rpcTiming),
+	}
+}
+
+// record adds a single call of method which took d.
+func (t *rpcTimings) record(method string, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	m, ok := t.methods[method]
+	if !ok {
+		m = &rpcTiming{}
+		t.methods[method] = m
+	}
+
+	m.calls++
+	m.total += d
+	m.max = max(m.max, d)
+}
+
+// print writes a table of the recorded timings, the slowest RPC first.
+// Since calls run concurrently, the totals may add up to more than the
+// elapsed time.
+func (t *rpcTimings) print(w io.Writer) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	methods := make([]string, 0, len(t.methods))
+	for method := range t.methods {
+		methods = append(methods, method)
+	}
+	sort.Slice(methods, func(i, j int) bool {
+		return t.methods[methods[i]].total > t.methods[methods[j]].total
+	})
+
+	const row = "%-20s |%6d |%10s |%10s\n"
+
+	fmt.Fprintf(w, "%-20s |%6s |%10s |%10s\n", "RPC", "Calls", "Total",
+		"Max")
+	for _, method := range methods {
+		m := t.methods[method]
+		fmt.Fprintf(w, row, method, m.calls,
+			m.total.Round(time.Millisecond),
+			m.max.Round(time.Millisecond))
+	}
+	fmt.Fprintf(w, "%-20s |%6s |%10s |\n", "Elapsed", "",
+		time.Since(t.start).Round(time.Millisecond))
+}
+
+// rpcClient wraps a backend so that the calls lnb makes are limited to a
+// number of concurrent calls, timed, and retried according to the policy.
+type rpcClient struct {
+	api     lndAPI
+	policy  retryPolicy
+	slots   chan struct{}
+	timings *rpcTimings
+}
+
+// newRPCClient wraps api allowing at most parallel calls to be in flight at
+// the same time.
+func newRPCClient(api lndAPI, policy retryPolicy, parallel int,
+	timings *rpcTimings) *rpcClient {
+
+	return &rpcClient{
+		api:     api,
+		policy:  policy,
+		slots:   make(chan struct{}, max(parallel, 1)),
+		timings: timings,
+	}
+}
+
+// attempt makes a single call once a slot is free and records its duration.
+func (c *rpcClient) attempt(ctx context.Context, method string,
+	call func(context.Context) error) error {
+
+	select {
+	case c.slots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-c.slots }()
+
+	start := time.Now()
+	err := call(ctx)
+	c.timings.record(method, time.Since(start))
+
+	return err
+}
+
+// withRetry runs call until it succeeds, fails with a non-transient error,
+// the retries of the policy are used up or ctx is cancelled. Between the
+// attempts it backs off exponentially, without holding a slot.
+func withRetry[T any](ctx context.Context, c *rpcClient, method string,
+	call func(context.Context) (T, error)) (T, error) {
+
+	backoff := initialRetryBackoff
+	for attempt := 1; ; attempt++ {
+		var resp T
+		err := c.attempt(ctx, method, func(ctx context.Context) error {
+			var err error
+			resp, err = call(ctx)
+			return err
+		})
+		if err == nil {
+			return resp, nil
+		}
+
+		if !isTransient(err) || attempt > c.policy.retries ||
+			ctx.Err() != nil {
+
+			var empty T
+			return empty, &rpcError{
+				method:   method,
+				attempts: attempt,
+				err:      err,
+			}
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			var empty T
+			return empty, &rpcError{
+				method:   method,
+				attempts: attempt,
+				err:      ctx.Err(),
+			}
+		}
+
+		backoff = min(2*backoff, maxRetryBackoff)
+	}
+}
+
+// GetInfo returns general information concerning the lightning node.
+func (c *rpcClient) GetInfo(ctx context.Context) (*lndclient.Info, error) {
+	return withRetry(ctx, c, "GetInfo",
+		func(ctx context.Context) (*lndclient.Info, error) {
+			return c.api.GetInfo(ctx)
+		},
+	)
+}
+
+// ListChannels retrieves all channels of the backing lnd node.
+func (c *rpcClient) ListChannels(ctx context.Context, activeOnly,
+	publicOnly bool, opts ...lndclient.ListChannelsOption) (
+	[]lndclient.ChannelInfo, error) {
+
+	return withRetry(ctx, c, "ListChannels",
+		func(ctx context.Context) ([]lndclient.ChannelInfo, error) {
+			return c.api.ListChannels(
+				ctx, activeOnly, publicOnly, opts...,
+			)
+		},
+	)
+}
+
+// ForwardingHistory makes a paginated call to our forwarding history
+// endpoint.
+func (c *rpcClient) ForwardingHistory(ctx context.Context,
+	req lndclient.ForwardingHistoryRequest) (
+	*lndclient.ForwardingHistoryResponse, error) {
+
+	return withRetry(ctx, c, "ForwardingHistory",
+		func(ctx context.Context) (*lndclient.ForwardingHistoryResponse,
+			error) {
+
+			return c.api.ForwardingHistory(ctx, req)
+		},
+	)
+}
+
+// GetNodeInfo returns the information of a node from the channel graph.
+func (c *rpcClient) GetNodeInfo(ctx context.Context, pubkey route.Vertex,
+	includeChannels bool) (*lndclient.NodeInfo, error) {
+
+	return withRetry(ctx, c, "GetNodeInfo",
+		func(ctx context.Context) (*lndclient.NodeInfo, error) {
+			return c.api.GetNodeInfo(
+				ctx, pubkey, includeChannels,
+			)
+		},
+	)
+}
+
+// GetChanInfo returns the channel edge, including both routing policies,
+// from the channel graph.
+func (c *rpcClient) GetChanInfo(ctx context.Context, chanID uint64) (
+	*lndclient.ChannelEdge, error) {
+
+	return withRetry(ctx, c, "GetChanInfo",
+		func(ctx context.Context) (*lndclient.ChannelEdge, error) {
+			return c.api.GetChanInfo(ctx, chanID)
+		},
+	)
+}
+
+// ClosedChannels returns all closed channels of the backing lnd node.
+func (c *rpcClient) ClosedChannels(ctx context.Context) (
+	[]lndclient.ClosedChannel, error) {
+
+	return withRetry(ctx, c, "ClosedChannels",
+		func(ctx context.Context) ([]lndclient.ClosedChannel, error) {
+			return c.api.ClosedChannels(ctx)
+		},
+	)
+}
+
+// PendingChannels returns the channels being opened or closed.
+func (c *rpcClient) PendingChannels(ctx context.Context) (
+	*lndclient.PendingChannels, error) {
+
+	return withRetry(ctx, c, "PendingChannels",
+		func(ctx context.Context) (*lndclient.PendingChannels, error) {
+			return c.api.PendingChannels(ctx)
+		},
+	)
+}
+
+// WalletBalance returns the on-chain balance of the wallet.
+func (c *rpcClient) WalletBalance(ctx context.Context) (
+	*lndclient.WalletBalance, error) {
+
+	return withRetry(ctx, c, "WalletBalance",
+		func(ctx context.Context) (*lndclient.WalletBalance, error) {
+			return c.api.WalletBalance(ctx)
+		},
+	)
+}
+
+// ListTransactions returns the on-chain transactions of the wallet between
+// the two block heights.
+func (c *rpcClient) ListTransactions(ctx context.Context, startHeight,
+	endHeight int32, opts ...lndclient.ListTransactionsOption) (
+	[]lndclient.Transaction, error) {
+
+	return withRetry(ctx, c, "ListTransactions",
+		func(ctx context.Context) ([]lndclient.Transaction, error) {
+			return c.api.ListTransactions(
+				ctx, startHeight, endHeight, opts...,
+			)
+		},
+	)
+}
+
+// ListPayments makes a paginated call to our payments endpoint.
+func (c *rpcClient) ListPayments(ctx context.Context,
+	req lndclient.ListPaymentsRequest) (*lndclient.ListPaymentsResponse,
+	error) {
+
+	return withRetry(ctx, c, "ListPayments",
+		func(ctx context.Context) (*lndclient.ListPaymentsResponse,
+			error) {
+
+			return c.api.ListPayments(ctx, req)
+		},
+	)
+}
+
+// channelSubscription holds the channels a subscription to channel events
+// streams on.
+type channelSubscription struct {
+	updates <-chan *lndclient.ChannelEventUpdate
+	errs    <-chan error
+}
+
+// SubscribeChannelEvents subscribes to the channel events of lnd. Only
+// setting up the subscription is retried, like for SubscribeHtlcEvents.
+func (c *rpcClient) SubscribeChannelEvents(ctx context.Context) (
+	<-chan *lndclient.ChannelEventUpdate, <-chan error, error) {
+
+	sub, err := withRetry(ctx, c, "SubscribeChannelEvents",
+		func(ctx context.Context) (channelSubscription, error) {
+			updates, errs, err := c.api.SubscribeChannelEvents(ctx)
+			return channelSubscription{updates, errs}, err
+		},
+	)
+
+	return sub.updates, sub.errs, err
+}
+
+// htlcSubscription holds the channels a subscription to HTLC events streams
+// on.
+type htlcSubscription struct {
+	events <-chan *routerrpc.HtlcEvent
+	errs   <-chan error
+}
+
+// SubscribeHtlcEvents subscribes to the HTLC events of the router. Only
+// setting up the subscription is retried, a stream which fails later on has
+// to be subscribed to again.
+func (c *rpcClient) SubscribeHtlcEvents(ctx context.Context) (
+	<-chan *routerrpc.HtlcEvent, <-chan error, error) {
+
+	sub, err := withRetry(ctx, c, "SubscribeHtlcEvents",
+		func(ctx context.Context) (htlcSubscription, error) {
+			events, errs, err := c.api.SubscribeHtlcEvents(ctx)
+			return htlcSubscription{events, errs}, err
+		},
+	)
+
+	return sub.events, sub.errs, err
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/routing/route"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// snapshotsFile is the store file channel snapshots are kept in.
+	snapshotsFile = "snapshots"
+
+	// trendWindow is the time the trend column of list channels covers,
+	// trendWidth the number of buckets it is split into.
+	trendWindow = 30 * 24 * time.Hour
+	trendWidth  = 15
+
+	// historyWidth is the number of buckets of the sparkline report
+	// history prints above each channel.
+	historyWidth = 40
+)
+
+// sparkBlocks are the glyphs of a sparkline, from an empty to a full
+// channel.
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// channelSnapshot is the state of all open channels at one time.
+type channelSnapshot struct {
+	Time     time.Time         `json:"time"`
+	Channels []snapshotChannel `json:"channels"`
+}
+
+// snapshotChannel is the state of a channel in a snapshot.
+type snapshotChannel struct {
+	ChannelID     uint64         `json:"chan_id"`
+	Active        bool           `json:"active"`
+	Capacity      btcutil.Amount `json:"capacity_sat"`
+	LocalBalance  btcutil.Amount `json:"local_balance_sat"`
+	RemoteBalance btcutil.Amount `json:"remote_balance_sat"`
+	TotalSent     btcutil.Amount `json:"total_sent_sat"`
+	TotalReceived btcutil.Amount `json:"total_received_sat"`
+}
+
+// ratio returns the share of the local balance in percent.
+func (c *snapshotChannel) ratio() float64 {
+	if c.LocalBalance+c.RemoteBalance == 0 {
+		return 0
+	}
+
+	return float64(c.LocalBalance) /
+		float64(c.LocalBalance+c.RemoteBalance) * 100
+}
+
+// ratioPoint is the ratio of a channel at one time.
+type ratioPoint struct {
+	time  time.Time
+	ratio float64
+}
+
+// channelRatios returns the ratios of every channel in the snapshots,
+// oldest first.
+func channelRatios(snapshots []channelSnapshot) map[uint64][]ratioPoint {
+	ratios := make(map[uint64][]ratioPoint)
+	for _, s := range snapshots {
+		for _, c := range s.Channels {
+			ratios[c.ChannelID] = append(
+				ratios[c.ChannelID],
+				ratioPoint{time: s.Time, ratio: c.ratio()},
+			)
+		}
+	}
+
+	return ratios
+}
+
+// sparkline draws the ratios between start and end as width glyphs. Each
+// glyph is the average ratio of its share of the time, a space if there are
+// no snapshots in it.
+func sparkline(points []ratioPoint, start, end time.Time, width int) string {
+	sums := make([]float64, width)
+	counts := make([]int, width)
+
+	span := end.Sub(start)
+	for _, p := range points {
+		if p.time.Before(start) || p.time.After(end) || span <= 0 {
+			continue
+		}
+
+		// Scaling in floats keeps spans of years from overflowing.
+		i := int(float64(p.time.Sub(start)) / float64(span) *
+			float64(width))
+		i = min(max(i, 0), width-1)
+		sums[i] += p.ratio
+		counts[i]++
+	}
+
+	line := make([]rune, width)
+	for i := range line {
+		if counts[i] == 0 {
+			line[i] = ' '
+			continue
+		}
+
+		avg := sums[i] / float64(counts[i])
+		level := int(math.Round(avg / 100 * float64(len(sparkBlocks)-1)))
+		line[i] = sparkBlocks[min(max(level, 0), len(sparkBlocks)-1)]
+	}
+
+	return string(line)
+}
+
+// loadSnapshots reads the snapshots of the node lnb is connected to.
+func loadSnapshots(callerCtx context.Context, ctx *cli.Context,
+	client lndAPI) ([]channelSnapshot, error) {
+
+	store, err := connectedStore(callerCtx, ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	return readRecords[channelSnapshot](store, snapshotsFile)
+}
+
+// takeSnapshot records the balances of all open channels in the store.
+func takeSnapshot(ctx *cli.Context) error {
+	ctxb := ctx.Context
+	client, err := getClient(ctxb, ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to LND: %w", err)
+	}
+	defer client.Close()
+
+	var (
+		info     *lndclient.Info
+		channels []lndclient.ChannelInfo
+	)
+	g, gctx := errgroup.WithContext(ctxb)
+	g.Go(func() error {
+		var err error
+		info, err = client.Client.GetInfo(gctx)
+		return err
+	})
+	g.Go(func() error {
+		var err error
+		channels, err = client.Client.ListChannels(gctx, false, false)
+		return err
+	})
+	if err := g.Wait(); err != nil {
+		return err
+	}
+
+	snapshot := channelSnapshot{
+		Time:     client.Now().UTC(),
+		Channels: make([]snapshotChannel, 0, len(channels)),
+	}
+	for _, c := range channels {
+		snapshot.Channels = append(snapshot.Channels, snapshotChannel{
+			ChannelID:     c.ChannelID,
+			Active:        c.Active,
+			Capacity:      c.Capacity,
+			LocalBalance:  c.LocalBalance,
+			RemoteBalance: c.RemoteBalance,
+			TotalSent:     c.TotalSent,
+			TotalReceived: c.TotalReceived,
+		})
+	}
+
+	store := openNodeStore(ctx, route.Vertex(info.IdentityPubkey))
+	if err := store.append(snapshotsFile, snapshot); err != nil {
+		return err
+	}
+
+	if !ctx.Bool("quiet") {
+		fmt.Printf("Recorded %d channels to %s\n", len(channels),
+			store.path(snapshotsFile))
+	}
+
+	return nil
+}
+
+// reportHistory prints how the balances of channels evolved over the
+// snapshots in the --since and --until range.
+func reportHistory(ctx *cli.Context) error {
+	ctxb := ctx.Context
+	client, err := getClient(ctxb, ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to LND: %w", err)
+	}
+	defer client.Close()
+
+	chanFmt, err := getChanFormat(ctx)
+	if err != nil {
+		return err
+	}
+
+	unit, err := getAmountUnit(ctx)
+	if err != nil {
+		return err
+	}
+
+	loc, err := time.LoadLocation(ctx.String("tz"))
+	if err != nil {
+		return fmt.Errorf("invalid --tz: %w", err)
+	}
+	now := client.Now()
+
+	start, err := parseTimeSpec(ctx.String("since"), now, loc)
+	if err != nil {
+		return fmt.Errorf("invalid --since: %w", err)
+	}
+
+	end := now
+	if ctx.IsSet("until") {
+		end, err = parseTimeSpec(ctx.String("until"), now, loc)
+		if err != nil {
+			return fmt.Errorf("invalid --until: %w", err)
+		}
+	}
+
+	if len(ctx.StringSlice("channel")) == 0 {
+		return fmt.Errorf("--channel is required")
+	}
+	ids, err := resolveChanIDs(ctxb, client.Client, ctx.StringSlice("channel"))
+	if err != nil {
+		return err
+	}
+
+	snapshots, err := loadSnapshots(ctxb, ctx, client.Client)
+	if err != nil {
+		return err
+	}
+
+	f := &rowFormat{chanFmt: chanFmt, unit: unit}
+	for i, id := range ids {
+		if i > 0 {
+			fmt.Println()
+		}
+
+		printHistory(id, snapshots, start, end, loc, f)
+	}
+
+	return nil
+}
+
+// printHistory prints the snapshots of a channel between start and end, with
+// a sparkline of its ratio.
+func printHistory(chanID uint64, snapshots []channelSnapshot,
+	start, end time.Time, loc *time.Location, f *rowFormat) {
+
+	var (
+		history []snapshotChannel
+		times   []time.Time
+		points  []ratioPoint
+	)
+	for _, s := range snapshots {
+		if s.Time.Before(start) || s.Time.After(end) {
+			continue
+		}
+
+		for _, c := range s.Channels {
+			if c.ChannelID != chanID {
+				continue
+			}
+
+			history = append(history, c)
+			times = append(times, s.Time)
+			points = append(points, ratioPoint{s.Time, c.ratio()})
+		}
+	}
+
+	fmt.Printf("Channel %s, %d snapshots\n",
+		strings.TrimSpace(f.chanFmt.format(chanID)), len(history))
+	if len(history) == 0 {
+		return
+	}
+	fmt.Printf("Ratio |%s|\n\n", sparkline(points, start, end, historyWidth))
+
+	table := newTextTable(
+		"Time", "Active", "Local", "Remote", "Ratio", "Total In",
+		"Total Out",
+	)
+	table.alignLeft(0)
+
+	for i, c := range history {
+		active := "yes"
+		if !c.Active {
+			active = "no"
+		}
+
+		table.addRow(
+			times[i].In(loc).Format(time.DateTime),
+			active,
+			f.unit.format(sat(c.LocalBalance)),
+			f.unit.format(sat(c.RemoteBalance)),
+			fmt.Sprintf("%d%%", int64(math.Round(c.ratio()))),
+			f.unit.format(sat(c.TotalReceived)),
+			f.unit.format(sat(c.TotalSent)),
+		)
+	}
+
+	first, last := history[0], history[len(history)-1]
+	table.setFooter(
+		"Change", "",
+		f.unit.formatSigned(int64(sat(last.LocalBalance-first.LocalBalance))),
+		f.unit.formatSigned(int64(sat(last.RemoteBalance-first.RemoteBalance))),
+		fmt.Sprintf("%+d%%", int64(math.Round(last.ratio()-first.ratio()))),
+		f.unit.format(sat(last.TotalReceived-first.TotalReceived)),
+		f.unit.format(sat(last.TotalSent-first.TotalSent)),
+	)
+
+	table.print()
+}
+
+// channelTrends returns the sparkline of the ratio of every channel over
+// the trend window.
+func channelTrends(snapshots []channelSnapshot,
+	now time.Time) map[uint64]string {
+
+	trends := make(map[uint64]string)
+	for id, points := range channelRatios(snapshots) {
+		trends[id] = sparkline(points, now.Add(-trendWindow), now,
+			trendWidth)
+	}
+
+	return trends
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestSparkline checks which bucket the ratios fall into and the glyph their
+// average is drawn with.
+func TestSparkline(t *testing.T) {
+	start := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(10 * 24 * time.Hour)
+	day := func(d float64) time.Time {
+		return start.Add(time.Duration(d * float64(24*time.Hour)))
+	}
+
+	tests := []struct {
+		name       string
+		points     []ratioPoint
+		start, end time.Time
+		width      int
+		want       string
+	}{
+		{
+			name:  "no points",
+			start: start,
+			end:   end,
+			width: 5,
+			want:  "     ",
+		},
+		{
+			name: "start and end are in the first and last bucket",
+			points: []ratioPoint{
+				{day(0), 0},
+				{day(10), 100},
+			},
+			start: start,
+			end:   end,
+			width: 5,
+			want:  "▁   █",
+		},
+		{
+			name: "bucket bounds belong to the later bucket",
+			points: []ratioPoint{
+				{day(1.999), 100},
+				{day(2), 0},
+				{day(4), 100},
+			},
+			start: start,
+			end:   end,
+			width: 5,
+			want:  "█▁█  ",
+		},
+		{
+			name: "points outside the range are left out",
+			points: []ratioPoint{
+				{day(-0.001), 100},
+				{day(5), 50},
+				{day(10.001), 100},
+			},
+			start: start,
+			end:   end,
+			width: 5,
+			want:  "  ▅  ",
+		},
+		{
+			name: "points in a bucket are averaged",
+			points: []ratioPoint{
+				{day(6), 0},
+				{day(7), 100},
+				{day(7.5), 50},
+			},
+			start: start,
+			end:   end,
+			width: 5,
+			want:  "   ▅ ",
+		},
+		{
+			name: "empty span",
+			points: []ratioPoint{
+				{start, 50},
+			},
+			start: start,
+			end:   start,
+			width: 3,
+			want:  "   ",
+		},
+		{
+			name: "a span of years doesn't overflow",
+			points: []ratioPoint{
+				{start.AddDate(-100, 0, 0), 0},
+				{start.AddDate(-50, 0, 0), 50},
+				{start, 100},
+			},
+			start: start.AddDate(-100, 0, 0),
+			end:   start,
+			width: 4,
+			want:  "▁ ▅█",
+		},
+	}
+
+	for _, test := range tests {
+		got := sparkline(test.points, test.start, test.end, test.width)
+		if got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
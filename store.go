@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/lightningnetwork/lnd/routing/route"
+	"github.com/urfave/cli/v2"
+)
+
+// nodeStore is the directory lnb keeps the history it records of a node in.
+// Each kind of record is appended to its own file as a line of JSON, which
+// keeps the files easy to inspect, back up and prune with standard tools.
+type nodeStore struct {
+	dir string
+}
+
+// openNodeStore returns the store of a node in --lnbdir. The directory is
+// only created once something is written to it.
+func openNodeStore(ctx *cli.Context, node route.Vertex) *nodeStore {
+	return &nodeStore{
+		dir: filepath.Join(
+			cleanAndExpandPath(ctx.String("lnbdir")),
+			hex.EncodeToString(node[:]),
+		),
+	}
+}
+
+// connectedStore returns the store of the node lnb is connected to.
+func connectedStore(callerCtx context.Context, ctx *cli.Context,
+	client lndAPI) (*nodeStore, error) {
+
+	info, err := client.GetInfo(callerCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	return openNodeStore(ctx, route.Vertex(info.IdentityPubkey)), nil
+}
+
+// path returns the path of the file the records of the given name are
+// kept in.
+func (s *nodeStore) path(name string) string {
+	return filepath.Join(s.dir, name+".jsonl")
+}
+
+// append writes the records to the end of the named file.
+func (s *nodeStore) append(name string, records ...interface{}) error {
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return fmt.Errorf("unable to create store: %w", err)
+	}
+
+	file, err := os.OpenFile(
+		s.path(name), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600,
+	)
+	if err != nil {
+		return fmt.Errorf("unable to open store: %w", err)
+	}
+
+	w := bufio.NewWriter(file)
+	encoder := json.NewEncoder(w)
+	for _, r := range records {
+		if err := encoder.Encode(r); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return fmt.Errorf("unable to write %s: %w", s.path(name), err)
+	}
+
+	return file.Close()
+}
+
+// readRecords reads all records of the named file, oldest first. A file
+// which doesn't exist yet holds no records.
+func readRecords[T any](s *nodeStore, name string) ([]T, error) {
+	file, err := os.Open(s.path(name))
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil, nil
+
+	case err != nil:
+		return nil, fmt.Errorf("unable to open store: %w", err)
+	}
+	defer file.Close()
+
+	var records []T
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 16<<20)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var r T
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", s.path(name),
+				lineNum, err)
+		}
+		records = append(records, r)
+	}
+
+	return records, scanner.Err()
+}
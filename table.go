@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// textTable prints rows of cells as a table whose columns are as wide as
+// their widest cell. Cells are right aligned unless their column is marked
+// as left aligned, which suits text rather than numbers.
+type textTable struct {
+	headers []string
+	left    []bool
+	rows    [][]string
+	footer  []string
+}
+
+// newTextTable returns an empty table with the given column headers.
+func newTextTable(headers ...string) *textTable {
+	return &textTable{
+		headers: headers,
+		left:    make([]bool, len(headers)),
+	}
+}
+
+// alignLeft marks the columns with the given indexes as left aligned.
+func (t *textTable) alignLeft(columns ...int) {
+	for _, c := range columns {
+		t.left[c] = true
+	}
+}
+
+// addRow appends a row. It must have a cell for every column.
+func (t *textTable) addRow(cells ...string) {
+	t.rows = append(t.rows, cells)
+}
+
+// setFooter sets the row printed below the others, e.g. the totals.
+func (t *textTable) setFooter(cells ...string) {
+	t.footer = cells
+}
+
+// print writes the table to stdout.
+func (t *textTable) print() {
+	widths := make([]int, len(t.headers))
+	for j, h := range t.headers {
+		widths[j] = len([]rune(h))
+	}
+	for _, row := range append(t.rows, t.footer) {
+		for j, cell := range row {
+			widths[j] = max(widths[j], len([]rune(cell)))
+		}
+	}
+
+	formatRow := func(row []string) string {
+		var b strings.Builder
+		for j, cell := range row {
+			if j > 0 {
+				b.WriteString("| ")
+			}
+
+			if t.left[j] {
+				fmt.Fprintf(&b, "%-*s ", widths[j], cell)
+			} else {
+				fmt.Fprintf(&b, "%*s ", widths[j], cell)
+			}
+		}
+
+		return strings.TrimRight(b.String(), " ")
+	}
+
+	title := formatRow(t.headers)
+	line := strings.Repeat("-", len([]rune(title)))
+
+	fmt.Println(title)
+	fmt.Println(line)
+
+	for _, row := range t.rows {
+		fmt.Println(formatRow(row))
+	}
+
+	if t.footer != nil {
+		fmt.Println(line)
+		fmt.Println(formatRow(t.footer))
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timeSpecLayouts are the absolute time formats accepted besides unix time.
+// Times without a zone are taken in the zone given with --tz.
+var timeSpecLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// timeSpecUnits are the units of relative times such as 7d.
+var timeSpecUnits = map[byte]time.Duration{
+	'm': time.Minute,
+	'h': time.Hour,
+	'd': 24 * time.Hour,
+	'w': 7 * 24 * time.Hour,
+}
+
+// parseTimeSpec parses a point in time given as
+//   - seconds since the unix epoch, e.g. 1756684800,
+//   - a duration back from now, e.g. 30m, 12h, 7d or 2w,
+//   - now, today or yesterday, the latter two meaning midnight,
+//   - a date or date and time, e.g. 2026-09-01 or 2026-09-01 18:30,
+//   - an RFC3339 timestamp, e.g. 2026-09-01T18:30:00+02:00.
+//
+// Dates and the day keywords are relative to loc.
+func parseTimeSpec(spec string, now time.Time, loc *time.Location) (time.Time,
+	error) {
+
+	spec = strings.TrimSpace(spec)
+	now = now.In(loc)
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+
+	switch strings.ToLower(spec) {
+	case "now":
+		return now, nil
+	case "today":
+		return midnight, nil
+	case "yesterday":
+		return midnight.AddDate(0, 0, -1), nil
+	}
+
+	if unix, err := strconv.ParseInt(spec, 10, 64); err == nil {
+		return time.Unix(unix, 0).In(loc), nil
+	}
+
+	if len(spec) > 1 {
+		unit, ok := timeSpecUnits[spec[len(spec)-1]]
+		n, err := strconv.ParseUint(spec[:len(spec)-1], 10, 32)
+		if ok && err == nil {
+			return now.Add(-time.Duration(n) * unit), nil
+		}
+	}
+
+	for _, layout := range timeSpecLayouts {
+		t, err := time.ParseInLocation(layout, spec, loc)
+		if err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("unable to parse time %q, expected "+
+		"unix time, a duration such as 7d, today, yesterday, a date "+
+		"such as 2026-09-01 or an RFC3339 timestamp", spec)
+}
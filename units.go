@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/shopspring/decimal"
+	"github.com/urfave/cli/v2"
+)
+
+// The units amounts can be printed in, see --unit.
+const (
+	unitMsat = "msat"
+	unitSat  = "sat"
+	unitBTC  = "btc"
+)
+
+// msatPerSat and msatPerBTC convert millisatoshis to the other units.
+var (
+	msatPerSat = decimal.NewFromInt(1000)
+	msatPerBTC = decimal.NewFromInt(1000 * btcutil.SatoshiPerBitcoin)
+)
+
+// amountUnit prints amounts in one of the units above.
+type amountUnit string
+
+// getAmountUnit returns the unit selected with --unit.
+func getAmountUnit(ctx *cli.Context) (amountUnit, error) {
+	switch u := ctx.String("unit"); u {
+	case unitMsat, unitSat, unitBTC:
+		return amountUnit(u), nil
+
+	default:
+		return "", fmt.Errorf("invalid --unit %q, should be %s, %s or %s",
+			u, unitMsat, unitSat, unitBTC)
+	}
+}
+
+// sat converts an amount in satoshis to millisatoshis.
+func sat(amt btcutil.Amount) lnwire.MilliSatoshi {
+	return lnwire.NewMSatFromSatoshis(amt)
+}
+
+// inUnit returns the amount in the unit.
+func (u amountUnit) inUnit(msat lnwire.MilliSatoshi) decimal.Decimal {
+	d := decimal.NewFromInt(int64(msat))
+
+	switch u {
+	case unitMsat:
+		return d
+	case unitBTC:
+		return d.Div(msatPerBTC)
+	default:
+		return d.Div(msatPerSat)
+	}
+}
+
+// value returns the amount in the unit for sorting, CSV and JSON: an int64
+// of millisatoshis, a float64 otherwise.
+func (u amountUnit) value(msat lnwire.MilliSatoshi) interface{} {
+	return u.signedValue(int64(msat))
+}
+
+// format prints the amount in the unit. Satoshis are printed whole unless
+// the amount has millisatoshis, bitcoin with all their decimals.
+func (u amountUnit) format(msat lnwire.MilliSatoshi) string {
+	switch u {
+	case unitMsat:
+		return strconv.FormatInt(int64(msat), 10)
+
+	case unitBTC:
+		if msat%1000 == 0 {
+			return u.inUnit(msat).StringFixed(8)
+		}
+
+		return u.inUnit(msat).StringFixed(11)
+
+	default:
+		if msat%1000 == 0 {
+			return strconv.FormatInt(int64(msat/1000), 10)
+		}
+
+		return formatFee(u.inUnit(msat))
+	}
+}
+
+// width is the width of a table column which fits amounts up to a few
+// bitcoin in the unit.
+func (u amountUnit) width() int {
+	if u == unitSat {
+		return 9
+	}
+
+	return 13
+}
+
+// formatSigned prints an amount which may be negative, e.g. a loss.
+func (u amountUnit) formatSigned(msat int64) string {
+	if msat < 0 {
+		return "-" + u.format(lnwire.MilliSatoshi(-msat))
+	}
+
+	return u.format(lnwire.MilliSatoshi(msat))
+}
+
+// signedValue is like value for amounts which may be negative.
+func (u amountUnit) signedValue(msat int64) interface{} {
+	if u == unitMsat {
+		return msat
+	}
+
+	if msat < 0 {
+		return -u.inUnit(lnwire.MilliSatoshi(-msat)).InexactFloat64()
+	}
+
+	return u.inUnit(lnwire.MilliSatoshi(msat)).InexactFloat64()
+}
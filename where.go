@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/hex"
+
+	"github.com/lightninglabs/lndclient"
+)
+
+// channelFields are the fields --where accepts for list channels. They are
+// the columns printChannels shows, amounts are in satoshis.
+var channelFields = exprFields{
+	"active":        exprBool,
+	"private":       exprBool,
+	"initiator":     exprBool,
+	"alias":         exprString,
+	"peer":          exprString,
+	"capacity":      exprNumber,
+	"local":         exprNumber,
+	"remote":        exprNumber,
+	"commit_fee":    exprNumber,
+	"ratio":         exprNumber,
+	"day_in":        exprNumber,
+	"day_out":       exprNumber,
+	"month_in":      exprNumber,
+	"month_out":     exprNumber,
+	"month_fee_sat": exprNumber,
+	"total_in":      exprNumber,
+	"total_out":     exprNumber,
+	"efficiency":    exprNumber,
+
+	"month_rebalance_fee_sat": exprNumber,
+	"month_net_fee_sat":       exprNumber,
+}
+
+// field returns the value of one of the channelFields.
+func (r ChannelRow) field(name string) exprValue {
+	switch name {
+	case "active":
+		return exprValue{flag: r.Active}
+	case "private":
+		return exprValue{flag: r.Private}
+	case "initiator":
+		return exprValue{flag: r.Initiator}
+	case "alias":
+		return exprValue{str: r.Alias}
+	case "peer":
+		return exprValue{str: hex.EncodeToString(r.PubKeyBytes[:])}
+	case "capacity":
+		return exprValue{num: float64(r.Capacity)}
+	case "local":
+		return exprValue{num: float64(r.LocalBalance)}
+	case "remote":
+		return exprValue{num: float64(r.RemoteBalance)}
+	case "commit_fee":
+		return exprValue{num: float64(r.CommitFee)}
+	case "ratio":
+		return exprValue{num: r.Ratio}
+	case "day_in":
+		return exprValue{num: float64(r.HTLC.Day.AmountSatIn)}
+	case "day_out":
+		return exprValue{num: float64(r.HTLC.Day.AmountSatOut)}
+	case "month_in":
+		return exprValue{num: float64(r.HTLC.Month.AmountSatIn)}
+	case "month_out":
+		return exprValue{num: float64(r.HTLC.Month.AmountSatOut)}
+	case "month_fee_sat":
+		return exprValue{num: r.MonthFee.InexactFloat64()}
+	case "total_in":
+		return exprValue{num: float64(r.TotalReceived)}
+	case "total_out":
+		return exprValue{num: float64(r.TotalSent)}
+	case "efficiency":
+		return exprValue{num: r.Efficiency}
+	case "month_rebalance_fee_sat":
+		return exprValue{num: float64(r.MonthRebalanceFee) / 1000}
+	case "month_net_fee_sat":
+		return exprValue{num: float64(r.monthNetFee()) / 1000}
+	default:
+		return exprValue{}
+	}
+}
+
+// contractFields are the fields --where accepts for list contracts, i.e.
+// the columns printContracts shows.
+var contractFields = exprFields{
+	"timestamp":  exprNumber,
+	"amount_in":  exprNumber,
+	"amount_out": exprNumber,
+	"fee_msat":   exprNumber,
+}
+
+// contractRecord returns the values of the contractFields of e.
+func contractRecord(e lndclient.ForwardingEvent) exprRecord {
+	return func(name string) exprValue {
+		switch name {
+		case "timestamp":
+			return exprValue{num: float64(e.Timestamp.Unix())}
+		case "amount_in":
+			return exprValue{num: float64(e.AmountMsatIn.ToSatoshis())}
+		case "amount_out":
+			return exprValue{num: float64(e.AmountMsatOut.ToSatoshis())}
+		case "fee_msat":
+			return exprValue{num: float64(e.FeeMsat)}
+		default:
+			return exprValue{}
+		}
+	}
+}